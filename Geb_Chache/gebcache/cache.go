@@ -3,32 +3,179 @@ package gebcache
 import (
 	"gebcache/lru"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// 返回当前时间，测试时替换
+var now = time.Now
+
+const (
+	defaultShards = 16
+	// 每个分片至少分到的字节数，缓存较小时减少分片数，避免按哈希分布不均时分片过早淘汰
+	minShardBytes = 64 << 10
+)
+
+// cache 按key的哈希分成多个独立加锁的分片，LRU等策略的Get也会修改内部结构，
+// 只用一把锁时并发读取会互相阻塞；各分片的字节数上限之和为cacheBytes
 type cache struct {
-	mu         sync.Mutex
-	lru        *lru.Cache
 	cacheBytes int64
+	// 分片数，为0时使用defaultShards
+	shards int
+	// 为nil时使用LRU
+	newPolicy func(maxBytes int64) lru.Policy
+	segments  []*segment
+
+	// 后台清理过期条目的间隔，为0时只在读取时删除
+	sweepInterval time.Duration
+	// 后台清理是否已启动，避免每次add都获取mu
+	sweeping int32
+	mu       sync.Mutex
+	stop     chan struct{}
+	closed   bool
 }
 
-func (c *cache) add(key string, value ByteView) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
-		c.lru = lru.New(c.cacheBytes, nil)
+type segment struct {
+	mu  sync.Mutex
+	lru *lru.Cache
+}
+
+// 缓存中的值，fresh之后到lru中的过期时间之前，值已过期但可以在后台刷新期间继续使用
+type cacheValue struct {
+	ByteView
+	// 零值表示不过期
+	fresh time.Time
+}
+
+// 创建分片，应用全部Option之后调用
+func (c *cache) init() {
+	n := c.shards
+	if n <= 0 {
+		n = defaultShards
+	}
+	if c.cacheBytes > 0 && int64(n) > c.cacheBytes/minShardBytes {
+		n = int(c.cacheBytes / minShardBytes)
+	}
+	if n < 1 {
+		n = 1
+	}
+	newPolicy := c.newPolicy
+	if newPolicy == nil {
+		newPolicy = lru.NewLRU
+	}
+	c.segments = make([]*segment, n)
+	for i := range c.segments {
+		// 余数分给前面的分片，使总和正好为cacheBytes
+		maxBytes := c.cacheBytes / int64(n)
+		if int64(i) < c.cacheBytes%int64(n) {
+			maxBytes++
+		}
+		l := lru.NewWithPolicy(maxBytes, nil, newPolicy(maxBytes))
+		l.Now = func() time.Time { return now() }
+		c.segments[i] = &segment{lru: l}
+	}
+}
+
+// 单个分片的最大字节数，即能放入缓存的最大条目，cacheBytes为0时返回0表示不限制
+func (c *cache) maxEntryBytes() int64 {
+	if c.cacheBytes <= 0 {
+		return 0
+	}
+	n := int64(len(c.segments))
+	// 余数分给了前面的分片
+	return (c.cacheBytes + n - 1) / n
+}
+
+// FNV-1a，不分配内存
+func (c *cache) segment(key string) *segment {
+	if len(c.segments) == 1 {
+		return c.segments[0]
 	}
-	c.lru.Add(key, value)
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return c.segments[h%uint32(len(c.segments))]
+}
+
+func (c *cache) add(key string, value ByteView, fresh, expire time.Time) {
+	s := c.segment(key)
+	s.mu.Lock()
+	s.lru.AddWithExpire(key, cacheValue{ByteView: value, fresh: fresh}, expire)
+	s.mu.Unlock()
+	if !expire.IsZero() && c.sweepInterval > 0 && atomic.LoadInt32(&c.sweeping) == 0 {
+		c.startSweep()
+	}
+}
+
+func (c *cache) get(key string) (value ByteView, fresh time.Time, ok bool) {
+	s := c.segment(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.lru.Get(key); ok {
+		cv := v.(cacheValue)
+		return cv.ByteView, cv.fresh, ok
+	}
+	return
+}
+
+func (c *cache) remove(key string) {
+	s := c.segment(key)
+	s.mu.Lock()
+	s.lru.Remove(key)
+	s.mu.Unlock()
 }
 
-func (c *cache) get(key string) (value ByteView, ok bool) {
+// 全部分片的条目数
+func (c *cache) len() int {
+	n := 0
+	for _, s := range c.segments {
+		s.mu.Lock()
+		n += s.lru.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+func (c *cache) startSweep() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.lru == nil {
+	if c.stop != nil || c.closed {
 		return
 	}
+	c.stop = make(chan struct{})
+	atomic.StoreInt32(&c.sweeping, 1)
+	go c.sweep(c.stop)
+}
 
-	if v, ok := c.lru.Get(key); ok {
-		return v.(ByteView), ok
+func (c *cache) sweep(stop chan struct{}) {
+	ticker := time.NewTicker(c.sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// 逐个分片加锁，清理期间其他分片的读写不受影响
+			for _, s := range c.segments {
+				s.mu.Lock()
+				s.lru.RemoveExpired()
+				s.mu.Unlock()
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// 停止后台清理
+func (c *cache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	// 关闭后不再启动后台清理
+	atomic.StoreInt32(&c.sweeping, 1)
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
 	}
-	return
 }
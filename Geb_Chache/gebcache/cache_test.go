@@ -0,0 +1,94 @@
+package gebcache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCacheShards(t *testing.T) {
+	for _, tt := range []struct {
+		cacheBytes int64
+		shards     int
+		want       int
+	}{
+		{cacheBytes: 64 << 20, want: defaultShards},
+		{cacheBytes: 64 << 20, shards: 3, want: 3},
+		// 每个分片至少64KB
+		{cacheBytes: 200 << 10, want: 3},
+		{cacheBytes: 100, want: 1},
+		{cacheBytes: 0, want: defaultShards},
+	} {
+		c := cache{cacheBytes: tt.cacheBytes, shards: tt.shards}
+		c.init()
+		assert.Len(t, c.segments, tt.want)
+	}
+
+	// 各分片的上限之和为cacheBytes
+	c := cache{cacheBytes: 1<<20 + 5, shards: 4}
+	c.init()
+	for i := 0; i < 10000; i++ {
+		key := strconv.Itoa(i)
+		c.add(key, ByteView{b: make([]byte, 200)}, time.Time{}, time.Time{})
+	}
+	var total int64
+	for _, s := range c.segments {
+		assert.LessOrEqual(t, s.lru.Bytes(), int64(1<<18+2))
+		total += s.lru.Bytes()
+	}
+	assert.LessOrEqual(t, total, int64(1<<20+5))
+	assert.Greater(t, total, int64(1<<20-4*210))
+
+	for i := 9990; i < 10000; i++ {
+		key := strconv.Itoa(i)
+		_, _, ok := c.get(key)
+		assert.True(t, ok)
+		c.remove(key)
+		_, _, ok = c.get(key)
+		assert.False(t, ok)
+	}
+}
+
+func benchmarkCache(b *testing.B, shards int, writeEvery int) {
+	const keys = 10000
+	c := cache{cacheBytes: 64 << 20, shards: shards}
+	c.init()
+	value := ByteView{b: make([]byte, 64)}
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = "key" + strconv.Itoa(i)
+		c.add(names[i], value, time.Time{}, time.Time{})
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			key := names[i%keys]
+			if writeEvery > 0 && i%writeEvery == 0 {
+				c.add(key, value, time.Time{}, time.Time{})
+			} else {
+				c.get(key)
+			}
+			i += 7
+		}
+	})
+}
+
+// 只有一个分片时与原先整个缓存共用一把锁相同
+func BenchmarkCacheGetParallel(b *testing.B) {
+	for _, shards := range []int{1, defaultShards} {
+		b.Run("shards="+strconv.Itoa(shards), func(b *testing.B) {
+			benchmarkCache(b, shards, 0)
+		})
+	}
+}
+
+// 读写比为9:1
+func BenchmarkCacheMixedParallel(b *testing.B) {
+	for _, shards := range []int{1, defaultShards} {
+		b.Run("shards="+strconv.Itoa(shards), func(b *testing.B) {
+			benchmarkCache(b, shards, 10)
+		})
+	}
+}
@@ -2,9 +2,13 @@ package gebcache
 
 import (
 	"fmt"
+	"gebcache/lru"
 	"gebcache/singleflight"
 	"log"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Getter interface {
@@ -17,33 +21,169 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+// TTLGetter 可以为每个值指定有效期的Getter：ttl为0时使用Group的默认值，小于0时不过期
+type TTLGetter interface {
+	GetWithTTL(key string) ([]byte, time.Duration, error)
+}
+
+type TTLGetterFunc func(key string) ([]byte, time.Duration, error)
+
+func (f TTLGetterFunc) Get(key string) ([]byte, error) {
+	data, _, err := f(key)
+	return data, err
+}
+
+func (f TTLGetterFunc) GetWithTTL(key string) ([]byte, time.Duration, error) {
+	return f(key)
+}
+
 type Group struct {
 	name      string
 	getter    Getter
 	mainCache cache
 	Peers     PeerGetter
 	sg        singleflight.Group
+
+	// 默认有效期，为0时不过期
+	ttl time.Duration
+	// 有效期上附加的[0, jitter)随机时间，避免同时加载的键同时过期
+	jitter time.Duration
+	// 过期后仍可返回旧值的时间，期间由一次后台加载刷新
+	stale time.Duration
+	// Set与Remove时是否通知全部节点删除副本
+	broadcast bool
+
+	// 从其他节点取到的热点key的副本，hotRate为0时不启用
+	hotCache cache
+	// 每hotRate次远程获取抽样一次放入hotCache
+	hotRate int
+	// hotCache中副本的有效期
+	hotTTL time.Duration
+
+	stats Stats
 }
 
+// Stats Group的统计数据
+type Stats struct {
+	// Get的调用次数
+	Gets int64
+	// mainCache命中的次数
+	Hits int64
+	// hotCache命中的次数，即节省的远程获取次数
+	HotHits int64
+	// 从其他节点获取成功的次数
+	PeerLoads int64
+	// 从其他节点获取失败的次数
+	PeerErrors int64
+	// 调用Getter的次数
+	LocalLoads int64
+}
+
+// Option NewGroup的可选配置
+type Option func(g *Group)
+
+// WithTTL 设置默认有效期
+func WithTTL(ttl time.Duration) Option {
+	return func(g *Group) {
+		g.ttl = ttl
+	}
+}
+
+// WithJitter 在每个值的有效期上附加[0, jitter)的随机时间
+func WithJitter(jitter time.Duration) Option {
+	return func(g *Group) {
+		g.jitter = jitter
+	}
+}
+
+// WithStaleWhileRevalidate 值过期后的stale时间内，Get直接返回旧值，
+// 同时在后台通过singleflight重新加载一次
+func WithStaleWhileRevalidate(stale time.Duration) Option {
+	return func(g *Group) {
+		g.stale = stale
+	}
+}
+
+// WithSweepInterval 设置后台清理过期值的间隔，默认1分钟，小于0时只在读取时删除
+func WithSweepInterval(interval time.Duration) Option {
+	return func(g *Group) {
+		g.mainCache.sweepInterval = interval
+	}
+}
+
+// WithBroadcast Set与Remove时除了key所属的节点，还通知其他全部节点删除本地的副本
+func WithBroadcast() Option {
+	return func(g *Group) {
+		g.broadcast = true
+	}
+}
+
+// WithPolicy 设置本地缓存的淘汰策略，默认为LRU：
+//
+//	gebcache.NewGroup("scores", 2<<20, peers, getter, gebcache.WithPolicy(lru.NewTinyLFU))
+func WithPolicy(newPolicy func(maxBytes int64) lru.Policy) Option {
+	return func(g *Group) {
+		g.mainCache.newPolicy = newPolicy
+	}
+}
+
+// WithHotCache 把从其他节点取到的值按1/rate的比例抽样复制到本节点，在ttl内直接返回，
+// 越热的key越容易被抽中；maxBytes为hotCache的字节数上限，通常为mainCache的1/8。
+// 副本在ttl内可能与所属节点不一致，Set、Remove与广播删除只清除本节点的副本；
+// rate必须为正数，ttl不为正数时使用defaultHotTTL
+func WithHotCache(maxBytes int64, rate int, ttl time.Duration) Option {
+	if rate <= 0 {
+		panic("gebcache: hot cache rate must be positive")
+	}
+	if ttl <= 0 {
+		ttl = defaultHotTTL
+	}
+	return func(g *Group) {
+		g.hotCache.cacheBytes = maxBytes
+		g.hotRate = rate
+		g.hotTTL = ttl
+	}
+}
+
+// WithShards 设置本地缓存的分片数，默认16，缓存较小时会自动减少，保证每个分片至少64KB
+func WithShards(n int) Option {
+	return func(g *Group) {
+		g.mainCache.shards = n
+	}
+}
+
+const (
+	defaultSweepInterval = time.Minute
+	defaultHotTTL        = time.Minute
+)
+
 var (
 	mu     sync.RWMutex
 	groups = make(map[string]*Group)
 )
 
-func NewGroupWithFunc(name string, cacheBytes int64, peerGetter PeerGetter, f GetterFunc) *Group {
-	return NewGroup(name, cacheBytes, peerGetter, f)
+func NewGroupWithFunc(name string, cacheBytes int64, peerGetter PeerGetter, f GetterFunc, opts ...Option) *Group {
+	return NewGroup(name, cacheBytes, peerGetter, f, opts...)
 }
 
-func NewGroup(name string, cacheBytes int64, peerGetter PeerGetter, getter Getter) *Group {
+func NewGroup(name string, cacheBytes int64, peerGetter PeerGetter, getter Getter, opts ...Option) *Group {
 	if getter == nil {
 		panic("nil Getter")
 	}
 	g := &Group{
 		name:      name,
 		getter:    getter,
-		mainCache: cache{cacheBytes: cacheBytes},
+		mainCache: cache{cacheBytes: cacheBytes, sweepInterval: defaultSweepInterval},
 		Peers:     peerGetter,
 	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.mainCache.init()
+	if g.hotRate > 0 {
+		g.hotCache.sweepInterval = g.mainCache.sweepInterval
+		g.hotCache.init()
+	}
 	mu.Lock()
 	groups[name] = g
 	mu.Unlock()
@@ -60,10 +200,25 @@ func (g *Group) Get(key string) ([]byte, error) {
 	if key == "" {
 		return nil, fmt.Errorf("key is required")
 	}
-	if v, ok := g.mainCache.get(key); ok {
-		log.Println("[GeeCache] hit")
+	atomic.AddInt64(&g.stats.Gets, 1)
+	if v, fresh, ok := g.mainCache.get(key); ok {
+		atomic.AddInt64(&g.stats.Hits, 1)
+		if fresh.IsZero() || now().Before(fresh) {
+			log.Println("[GeeCache] hit")
+			return v.Data(), nil
+		}
+		// 已过期但仍在stale时间内，返回旧值并在后台刷新，加载失败时旧值保留到stale时间结束
+		g.sg.Start(key, func() (interface{}, error) {
+			return g.load(key)
+		})
 		return v.Data(), nil
 	}
+	if g.hotRate > 0 {
+		if v, _, ok := g.hotCache.get(key); ok {
+			atomic.AddInt64(&g.stats.HotHits, 1)
+			return v.Data(), nil
+		}
+	}
 
 	v, err := g.sg.Do(key, func() (interface{}, error) {
 		return g.load(key)
@@ -77,10 +232,15 @@ func (g *Group) load(key string) ([]byte, error) {
 	if g.Peers != nil {
 		data, err := g.Peers.Get(g.name, key)
 		if err != nil {
+			atomic.AddInt64(&g.stats.PeerErrors, 1)
 			// 如果远端出现错误，尝试从本地获取
 			return g.getLocally(key)
 		}
 		if len(data) != 0 {
+			atomic.AddInt64(&g.stats.PeerLoads, 1)
+			if g.hotRate > 0 && rand.Intn(g.hotRate) == 0 {
+				g.hotCache.add(key, ByteView{cloneBytes(data)}, time.Time{}, now().Add(g.hotTTL))
+			}
 			return data, nil
 		}
 	}
@@ -89,15 +249,116 @@ func (g *Group) load(key string) ([]byte, error) {
 
 // TODO:如果本地没有数据，应该防止再次从本地获取数据
 func (g *Group) getLocally(key string) ([]byte, error) {
-	data, err := g.getter.Get(key)
+	var data []byte
+	var ttl time.Duration
+	var err error
+	atomic.AddInt64(&g.stats.LocalLoads, 1)
+	if tg, ok := g.getter.(TTLGetter); ok {
+		data, ttl, err = tg.GetWithTTL(key)
+	} else {
+		data, err = g.getter.Get(key)
+	}
 	if err != nil {
 		return nil, err
 	}
 	value := ByteView{cloneBytes(data)}
-	g.populateCache(key, value)
+	g.populateCache(key, value, ttl)
 	return value.Data(), nil
 }
 
-func (g *Group) populateCache(key string, value ByteView) {
-	g.mainCache.add(key, value)
+// ttl为0时使用默认有效期，小于0时不过期
+func (g *Group) populateCache(key string, value ByteView, ttl time.Duration) {
+	if ttl == 0 {
+		ttl = g.ttl
+	}
+	if ttl <= 0 {
+		g.mainCache.add(key, value, time.Time{}, time.Time{})
+		return
+	}
+	if g.jitter > 0 {
+		ttl += time.Duration(rand.Int63n(int64(g.jitter)))
+	}
+	fresh := now().Add(ttl)
+	g.mainCache.add(key, value, fresh, fresh.Add(g.stale))
+}
+
+// Set 使用默认有效期写入key的值
+func (g *Group) Set(key string, value []byte) error {
+	return g.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL 写入key的值，ttl的含义与TTLGetter相同；
+// Peers实现了PeerUpdater时写入key所属的节点，本节点只删除可能存在的旧副本
+func (g *Group) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if u, ok := g.Peers.(PeerUpdater); ok {
+		remote, err := u.Set(g.name, key, value, ttl)
+		if err != nil {
+			return err
+		}
+		if remote {
+			g.removeLocally(key)
+			return g.purge(u, key)
+		}
+	}
+	if g.hotRate > 0 {
+		g.hotCache.remove(key)
+	}
+	g.populateCache(key, ByteView{cloneBytes(value)}, ttl)
+	if u, ok := g.Peers.(PeerUpdater); ok {
+		return g.purge(u, key)
+	}
+	return nil
+}
+
+// Remove 删除key，Peers实现了PeerUpdater时同时从key所属的节点删除
+func (g *Group) Remove(key string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	g.removeLocally(key)
+	if u, ok := g.Peers.(PeerUpdater); ok {
+		if _, err := u.Remove(g.name, key); err != nil {
+			return err
+		}
+		return g.purge(u, key)
+	}
+	return nil
+}
+
+func (g *Group) purge(u PeerUpdater, key string) error {
+	if !g.broadcast {
+		return nil
+	}
+	return u.Purge(g.name, key)
+}
+
+// 删除本节点的值与副本
+func (g *Group) removeLocally(key string) {
+	g.mainCache.remove(key)
+	if g.hotRate > 0 {
+		g.hotCache.remove(key)
+	}
+}
+
+// Stats 返回统计数据的快照
+func (g *Group) Stats() Stats {
+	return Stats{
+		Gets:       atomic.LoadInt64(&g.stats.Gets),
+		Hits:       atomic.LoadInt64(&g.stats.Hits),
+		HotHits:    atomic.LoadInt64(&g.stats.HotHits),
+		PeerLoads:  atomic.LoadInt64(&g.stats.PeerLoads),
+		PeerErrors: atomic.LoadInt64(&g.stats.PeerErrors),
+		LocalLoads: atomic.LoadInt64(&g.stats.LocalLoads),
+	}
+}
+
+// Close 停止后台清理，Group仍然可以使用
+func (g *Group) Close() {
+	g.mainCache.close()
+	if g.hotRate > 0 {
+		g.hotCache.close()
+	}
 }
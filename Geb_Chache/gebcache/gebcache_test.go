@@ -2,9 +2,12 @@ package gebcache
 
 import (
 	"fmt"
+	"gebcache/lru"
 	"github.com/stretchr/testify/assert"
 	"log"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var db = map[string]string{
@@ -40,3 +43,269 @@ func TestGet(t *testing.T) {
 		assert.Equal(t, 1, v)
 	}
 }
+
+// 替换当前时间，返回用于前进时间的函数
+func fakeNow(t *testing.T) func(d time.Duration) {
+	var cur int64 = time.Unix(1000, 0).UnixNano()
+	old := now
+	now = func() time.Time { return time.Unix(0, atomic.LoadInt64(&cur)) }
+	t.Cleanup(func() { now = old })
+	return func(d time.Duration) {
+		atomic.AddInt64(&cur, int64(d))
+	}
+}
+
+func TestTTL(t *testing.T) {
+	advance := fakeNow(t)
+	var loads int64
+	g := NewGroup("ttl", 2<<10, nil, TTLGetterFunc(func(key string) ([]byte, time.Duration, error) {
+		n := atomic.AddInt64(&loads, 1)
+		switch key {
+		case "short":
+			return []byte(fmt.Sprint(n)), time.Second, nil
+		case "forever":
+			return []byte(fmt.Sprint(n)), -1, nil
+		}
+		return []byte(fmt.Sprint(n)), 0, nil
+	}), WithTTL(time.Minute), WithSweepInterval(-1))
+
+	for _, k := range []string{"short", "default", "forever"} {
+		_, err := g.Get(k)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int64(3), loads)
+
+	advance(time.Second)
+	d, _ := g.Get("short")
+	assert.Equal(t, "4", string(d))
+	d, _ = g.Get("default")
+	assert.Equal(t, "2", string(d))
+
+	advance(time.Minute)
+	d, _ = g.Get("default")
+	assert.Equal(t, "5", string(d))
+	d, _ = g.Get("forever")
+	assert.Equal(t, "3", string(d))
+}
+
+func TestJitter(t *testing.T) {
+	fakeNow(t)
+	g := NewGroupWithFunc("jitter", 2<<10, nil, func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	WithTTL(time.Minute)(g)
+	WithJitter(time.Second)(g)
+	start := now()
+	seen := map[time.Time]bool{}
+	for i := 0; i < 20; i++ {
+		g.populateCache(fmt.Sprint(i), ByteView{}, 0)
+		_, fresh, ok := g.mainCache.get(fmt.Sprint(i))
+		assert.True(t, ok)
+		assert.False(t, fresh.Before(start.Add(time.Minute)))
+		assert.True(t, fresh.Before(start.Add(time.Minute+time.Second)))
+		seen[fresh] = true
+	}
+	assert.True(t, len(seen) > 1)
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	advance := fakeNow(t)
+	var loads int64
+	release := make(chan struct{}, 1)
+	g := NewGroupWithFunc("swr", 2<<10, nil, func(key string) ([]byte, error) {
+		n := atomic.AddInt64(&loads, 1)
+		if n > 1 {
+			<-release
+		}
+		return []byte(fmt.Sprint(n)), nil
+	})
+	WithTTL(time.Second)(g)
+	WithStaleWhileRevalidate(time.Minute)(g)
+
+	d, _ := g.Get("k")
+	assert.Equal(t, "1", string(d))
+	advance(2 * time.Second)
+	// 多次读取旧值只触发一次加载
+	for i := 0; i < 5; i++ {
+		d, err := g.Get("k")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", string(d))
+	}
+	release <- struct{}{}
+	assert.Eventually(t, func() bool {
+		d, _ := g.Get("k")
+		return string(d) == "2"
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&loads))
+
+	// 超过stale时间后同步加载
+	advance(2 * time.Minute)
+	release <- struct{}{}
+	d, _ = g.Get("k")
+	assert.Equal(t, "3", string(d))
+}
+
+func TestSweep(t *testing.T) {
+	advance := fakeNow(t)
+	g := NewGroupWithFunc("sweep", 2<<10, nil, func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}, WithTTL(time.Second), WithSweepInterval(time.Millisecond))
+	defer g.Close()
+	g.Get("a")
+	g.Get("b")
+	advance(time.Second)
+	assert.Eventually(t, func() bool {
+		return g.mainCache.len() == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestPolicy(t *testing.T) {
+	var maxBytes int64
+	g := NewGroupWithFunc("policy", 8, nil, func(key string) ([]byte, error) {
+		return []byte("1"), nil
+	}, WithPolicy(func(n int64) lru.Policy {
+		maxBytes = n
+		return lru.NewLFU(n)
+	}))
+	defer g.Close()
+	for _, key := range []string{"a", "a", "a", "b", "c", "d", "e"} {
+		_, err := g.Get(key)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int64(8), maxBytes)
+	// LRU会淘汰最早加入的a，LFU保留访问次数最多的a
+	_, _, ok := g.mainCache.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 4, g.mainCache.len())
+}
+
+// 记录调用的PeerGetter与PeerUpdater，remote决定key是否属于其他节点
+type fakePeers struct {
+	remote bool
+	calls  []string
+}
+
+func (p *fakePeers) Get(group, key string) ([]byte, error) {
+	p.calls = append(p.calls, "get "+key)
+	if p.remote {
+		return []byte("remote"), nil
+	}
+	return nil, nil
+}
+
+func (p *fakePeers) Set(group, key string, value []byte, ttl time.Duration) (bool, error) {
+	p.calls = append(p.calls, fmt.Sprintf("set %s %s %v", key, value, ttl))
+	return p.remote, nil
+}
+
+func (p *fakePeers) Remove(group, key string) (bool, error) {
+	p.calls = append(p.calls, "remove "+key)
+	return p.remote, nil
+}
+
+func (p *fakePeers) Purge(group, key string) error {
+	p.calls = append(p.calls, "purge "+key)
+	return nil
+}
+
+func TestSetRemove(t *testing.T) {
+	g := NewGroupWithFunc("set", 2<<10, nil, func(key string) ([]byte, error) {
+		return []byte("db"), nil
+	})
+	assert.Error(t, g.Set("", []byte("v")))
+	assert.Nil(t, g.Set("k", []byte("v")))
+	d, _ := g.Get("k")
+	assert.Equal(t, "v", string(d))
+	assert.Nil(t, g.Remove("k"))
+	d, _ = g.Get("k")
+	assert.Equal(t, "db", string(d))
+
+	// key属于本节点
+	peers := &fakePeers{}
+	g = NewGroupWithFunc("set-local", 2<<10, peers, func(key string) ([]byte, error) {
+		return []byte("db"), nil
+	})
+	assert.Nil(t, g.SetWithTTL("k", []byte("v"), time.Hour))
+	d, _ = g.Get("k")
+	assert.Equal(t, "v", string(d))
+	assert.Equal(t, []string{"set k v 1h0m0s"}, peers.calls)
+
+	// key属于其他节点，本地的副本被删除
+	peers = &fakePeers{remote: true}
+	g = NewGroupWithFunc("set-remote", 2<<10, peers, func(key string) ([]byte, error) {
+		return []byte("db"), nil
+	}, WithBroadcast())
+	g.populateCache("k", ByteView{[]byte("old")}, 0)
+	assert.Nil(t, g.Set("k", []byte("v")))
+	_, _, ok := g.mainCache.get("k")
+	assert.False(t, ok)
+	assert.Nil(t, g.Remove("k"))
+	assert.Equal(t, []string{"set k v 0s", "purge k", "remove k", "purge k"}, peers.calls)
+}
+
+func TestHotCache(t *testing.T) {
+	advance := fakeNow(t)
+	peers := &fakePeers{remote: true}
+	g := NewGroupWithFunc("hot", 2<<10, peers, func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	}, WithHotCache(1<<10, 1, time.Second))
+	defer g.Close()
+
+	for i := 0; i < 3; i++ {
+		d, err := g.Get("k")
+		assert.Nil(t, err)
+		assert.Equal(t, "remote", string(d))
+	}
+	assert.Equal(t, []string{"get k"}, peers.calls)
+	assert.Equal(t, Stats{Gets: 3, HotHits: 2, PeerLoads: 1}, g.Stats())
+	// 副本不进入mainCache
+	_, _, ok := g.mainCache.get("k")
+	assert.False(t, ok)
+
+	// 副本过期后重新从所属节点获取
+	advance(time.Second)
+	g.Get("k")
+	assert.Equal(t, []string{"get k", "get k"}, peers.calls)
+
+	// 删除时同时清除副本
+	assert.Nil(t, g.Remove("k"))
+	g.Get("k")
+	assert.Equal(t, []string{"get k", "get k", "remove k", "get k"}, peers.calls)
+	assert.Equal(t, Stats{Gets: 5, HotHits: 2, PeerLoads: 3}, g.Stats())
+
+	// 远程获取失败时从本地加载
+	g.hotCache.remove("k")
+	peers.remote = false
+	d, _ := g.Get("k")
+	assert.Equal(t, "local", string(d))
+	assert.Equal(t, int64(1), g.Stats().LocalLoads)
+	g.Get("k")
+	assert.Equal(t, int64(1), g.Stats().Hits)
+}
+
+func TestHotCacheSampling(t *testing.T) {
+	peers := &fakePeers{remote: true}
+	g := NewGroupWithFunc("hot-sampling", 2<<10, peers, func(key string) ([]byte, error) {
+		return nil, nil
+	}, WithHotCache(1<<10, 10, time.Hour))
+	defer g.Close()
+	for i := 0; i < 1000 && g.Stats().HotHits == 0; i++ {
+		g.Get("k")
+	}
+	// 平均每10次远程获取抽样一次
+	s := g.Stats()
+	assert.Equal(t, int64(1), s.HotHits)
+	assert.Equal(t, s.Gets-1, s.PeerLoads)
+}
+
+func TestHotCacheOption(t *testing.T) {
+	assert.Panics(t, func() { WithHotCache(1<<10, 0, time.Second) })
+	assert.Panics(t, func() { WithHotCache(1<<10, -1, time.Second) })
+
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		g := &Group{}
+		WithHotCache(1<<10, 1, ttl)(g)
+		assert.Equal(t, defaultHotTTL, g.hotTTL)
+		assert.Equal(t, 1, g.hotRate)
+	}
+}
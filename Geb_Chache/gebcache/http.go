@@ -1,36 +1,46 @@
 package gebcache
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 )
 
 const defaultBasePath = "/gebcache/"
 
+// 缓存不限制大小时，PUT请求体的上限
+const defaultMaxValueBytes = 32 << 20
+
 type HTTPPool struct {
 	self     string
 	basePath string
 	mu       sync.Mutex
 	picker   Picker
-	getters  map[string]PeerGetter
+	getters  map[string]*httpGetter
 }
 
 type httpGetter struct {
 	addr string
 }
 
+func (getter *httpGetter) url(group, key string) string {
+	return fmt.Sprintf("%v%v/%v", getter.addr, url.QueryEscape(group), url.QueryEscape(key))
+}
+
 func (getter *httpGetter) Get(group, key string) ([]byte, error) {
-	u := fmt.Sprintf("%v%v/%v", getter.addr, url.QueryEscape(group), url.QueryEscape(key))
+	u := getter.url(group, key)
 	res, err := http.Get(u)
-	defer res.Body.Close()
 	if err != nil {
 		return nil, err
 	}
+	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("server returned %v", res.Status)
 	}
@@ -41,6 +51,40 @@ func (getter *httpGetter) Get(group, key string) ([]byte, error) {
 	return bytes, nil
 }
 
+// Set 写入对端的缓存，ttl为0时使用对端Group的默认有效期
+func (getter *httpGetter) Set(group, key string, value []byte, ttl time.Duration) error {
+	u := getter.url(group, key)
+	if ttl != 0 {
+		u += "?ttl=" + url.QueryEscape(ttl.String())
+	}
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	return do(req)
+}
+
+// Remove 从对端的缓存中删除key
+func (getter *httpGetter) Remove(group, key string) error {
+	req, err := http.NewRequest(http.MethodDelete, getter.url(group, key), nil)
+	if err != nil {
+		return err
+	}
+	return do(req)
+}
+
+func do(req *http.Request) error {
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("server returned %v", res.Status)
+	}
+	return nil
+}
+
 func (p *HTTPPool) AddPeers(key ...string) {
 	for _, k := range key {
 		p.getters[k] = &httpGetter{
@@ -61,6 +105,46 @@ func (p *HTTPPool) Get(group, key string) ([]byte, error) {
 	return p.getters[ps].Get(group, key)
 }
 
+func (p *HTTPPool) Set(group, key string, value []byte, ttl time.Duration) (bool, error) {
+	ps := p.picker.Pick(key)
+	if ps == p.self {
+		return false, nil
+	}
+	p.Log("Setting key [%s:%s] on server [%s]", group, key, ps)
+	return true, p.getters[ps].Set(group, key, value, ttl)
+}
+
+func (p *HTTPPool) Remove(group, key string) (bool, error) {
+	ps := p.picker.Pick(key)
+	if ps == p.self {
+		return false, nil
+	}
+	p.Log("Removing key [%s:%s] from server [%s]", group, key, ps)
+	return true, p.getters[ps].Remove(group, key)
+}
+
+// Purge 并发地通知其他节点删除key，返回遇到的第一个错误
+func (p *HTTPPool) Purge(group, key string) error {
+	owner := p.picker.Pick(key)
+	var wg sync.WaitGroup
+	errs := make(chan error, len(p.getters))
+	for peer, getter := range p.getters {
+		if peer == p.self || peer == owner {
+			continue
+		}
+		wg.Add(1)
+		go func(peer string, getter *httpGetter) {
+			defer wg.Done()
+			if err := getter.Remove(group, key); err != nil {
+				errs <- fmt.Errorf("purge %s: %v", peer, err)
+			}
+		}(peer, getter)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
 func NewHTTPPool(self, basePath string, picker Picker) *HTTPPool {
 	if len(basePath) == 0 {
 		basePath = defaultBasePath
@@ -75,7 +159,7 @@ func NewHTTPPool(self, basePath string, picker Picker) *HTTPPool {
 		self:     self,
 		basePath: basePath,
 		picker:   picker,
-		getters:  make(map[string]PeerGetter),
+		getters:  make(map[string]*httpGetter),
 	}
 }
 
@@ -101,9 +185,49 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	switch r.Method {
+	case http.MethodPut:
+		// 只写入本节点，不再转发
+		var ttl time.Duration
+		if v := r.URL.Query().Get("ttl"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				http.Error(w, "bad ttl: "+v, http.StatusBadRequest)
+				return
+			}
+			ttl = d
+		}
+		// 条目只会放入一个分片，超过分片大小的值放入后会被立即淘汰，不必读入内存
+		limit := int64(defaultMaxValueBytes)
+		if max := group.mainCache.maxEntryBytes(); max > 0 {
+			limit = max - int64(len(key))
+		}
+		if r.ContentLength > limit {
+			http.Error(w, "value too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		value, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if int64(len(value)) > limit {
+			http.Error(w, "value too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		group.populateCache(key, ByteView{value}, ttl)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case http.MethodDelete:
+		group.removeLocally(key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	data, err := group.Get(key)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/octet-stream")
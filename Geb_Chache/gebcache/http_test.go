@@ -3,10 +3,15 @@ package gebcache
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestHTTPPool_ServerHTTP(t *testing.T) {
@@ -32,3 +37,130 @@ func TestHTTPPool_ServerHTTP(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "630", w.Body.String())
 }
+
+type fixedPicker string
+
+func (p fixedPicker) Add(key ...string) {}
+
+func (p fixedPicker) Pick(key string) string {
+	return string(p)
+}
+
+func TestHTTPPool_Update(t *testing.T) {
+	var mu sync.Mutex
+	var reqs []string
+	record := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			mu.Lock()
+			reqs = append(reqs, name+" "+r.Method+" "+r.URL.RequestURI()+" "+string(body))
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	}
+	a, b, c := record("a"), record("b"), record("c")
+	defer a.Close()
+	defer b.Close()
+	defer c.Close()
+
+	pool := NewHTTPPool("self", "", fixedPicker(a.URL))
+	pool.AddPeers("self", a.URL, b.URL, c.URL)
+	remote, err := pool.Set("scores", "tom", []byte("700"), time.Second)
+	assert.True(t, remote)
+	assert.Nil(t, err)
+	remote, err = pool.Remove("scores", "tom")
+	assert.True(t, remote)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"a PUT /gebcache/scores/tom?ttl=1s 700",
+		"a DELETE /gebcache/scores/tom ",
+	}, reqs)
+
+	// 通知除本节点与所属节点之外的节点
+	reqs = nil
+	assert.Nil(t, pool.Purge("scores", "tom"))
+	sort.Strings(reqs)
+	assert.Equal(t, []string{
+		"b DELETE /gebcache/scores/tom ",
+		"c DELETE /gebcache/scores/tom ",
+	}, reqs)
+
+	// key属于本节点时不转发
+	reqs = nil
+	pool = NewHTTPPool("self", "", fixedPicker("self"))
+	pool.AddPeers("self", a.URL)
+	remote, err = pool.Set("scores", "tom", []byte("700"), 0)
+	assert.False(t, remote)
+	assert.Nil(t, err)
+	assert.Empty(t, reqs)
+
+	c.Close()
+	pool = NewHTTPPool("self", "", fixedPicker(c.URL))
+	pool.AddPeers(c.URL)
+	_, err = pool.Remove("scores", "tom")
+	assert.Error(t, err)
+}
+
+func TestHTTPPool_ServeUpdate(t *testing.T) {
+	g := NewGroupWithFunc("update", 2<<10, nil, func(key string) ([]byte, error) {
+		return []byte("db"), nil
+	})
+	pool := NewHTTPPool("localhost:9999", "", nil)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		pool.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	assert.Equal(t, http.StatusNoContent, serve("PUT", "/gebcache/update/k", "v1").Code)
+	d, err := g.Get("k")
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", string(d))
+
+	assert.Equal(t, http.StatusNoContent, serve("DELETE", "/gebcache/update/k", "").Code)
+	d, _ = g.Get("k")
+	assert.Equal(t, "db", string(d))
+
+	assert.Equal(t, http.StatusBadRequest, serve("PUT", "/gebcache/update/k?ttl=x", "v1").Code)
+	assert.Equal(t, http.StatusNoContent, serve("PUT", "/gebcache/update/k?ttl=1h", "v2").Code)
+	_, fresh, ok := g.mainCache.get("k")
+	assert.True(t, ok)
+	assert.False(t, fresh.IsZero())
+
+	// 条目（key与值）超过缓存大小时返回413，不写入缓存
+	big := strings.Repeat("x", 2<<10-len("big")+1)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve("PUT", "/gebcache/update/big", big).Code)
+	req := httptest.NewRequest("PUT", "/gebcache/update/big", strings.NewReader(big))
+	// 没有Content-Length时按实际读取的字节数判断
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	pool.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	_, _, ok = g.mainCache.get("big")
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusNoContent, serve("PUT", "/gebcache/update/big", big[1:]).Code)
+	_, _, ok = g.mainCache.get("big")
+	assert.True(t, ok)
+
+	// 分片后按单个分片的大小限制
+	sharded := NewGroupWithFunc("update-sharded", 1<<20, nil, func(key string) ([]byte, error) {
+		return nil, nil
+	})
+	assert.Len(t, sharded.mainCache.segments, 16)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve("PUT", "/gebcache/update-sharded/k", strings.Repeat("x", 100<<10)).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve("PUT", "/gebcache/update-sharded/k", strings.Repeat("x", 64<<10)).Code)
+	assert.Equal(t, http.StatusNoContent, serve("PUT", "/gebcache/update-sharded/k", strings.Repeat("x", 64<<10-1)).Code)
+	_, _, ok = sharded.mainCache.get("k")
+	assert.True(t, ok)
+
+	// 广播删除同时清除热点副本
+	hot := NewGroupWithFunc("update-hot", 2<<10, &fakePeers{remote: true}, func(key string) ([]byte, error) {
+		return nil, nil
+	}, WithHotCache(1<<10, 1, time.Hour))
+	defer hot.Close()
+	hot.Get("k")
+	_, _, ok = hot.hotCache.get("k")
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNoContent, serve("DELETE", "/gebcache/update-hot/k", "").Code)
+	_, _, ok = hot.hotCache.get("k")
+	assert.False(t, ok)
+}
@@ -0,0 +1,105 @@
+package lru
+
+type arc struct {
+	// t1只被访问过一次，t2被访问过多次，b1、b2分别记录从t1、t2淘汰的key
+	t1, t2 *sizedList
+	b1, b2 *ghost
+	// t1的目标字节数，命中b1时增大，命中b2时减小
+	p int64
+	c int64
+}
+
+// NewARC 在最近访问与访问频率之间自适应调整的ARC策略，按字节数而不是条目数计算各队列的大小
+func NewARC(maxBytes int64) Policy {
+	return &arc{
+		t1: newSizedList(),
+		t2: newSizedList(),
+		b1: newGhost(),
+		b2: newGhost(),
+		c:  maxBytes,
+	}
+}
+
+func (p *arc) Add(e *Entry) {
+	size := e.Size()
+	switch {
+	case p.b1.remove(e.Key):
+		// 最近淘汰的新条目再次被访问，说明t1太小
+		delta := size
+		if p.b1.bytes > 0 && p.b2.bytes > p.b1.bytes {
+			delta = size * (p.b2.bytes / p.b1.bytes)
+		}
+		p.p = min64(p.c, p.p+delta)
+		e.list = inFrequent
+		p.t2.pushFront(e)
+	case p.b2.remove(e.Key):
+		delta := size
+		if p.b2.bytes > 0 && p.b1.bytes > p.b2.bytes {
+			delta = size * (p.b1.bytes / p.b2.bytes)
+		}
+		p.p = max64(0, p.p-delta)
+		e.list = inFrequent
+		p.t2.pushFront(e)
+	default:
+		// 只在新key加入时裁剪历史，淘汰时t1、t2中还包含正被淘汰的条目
+		p.trim()
+		e.list = inRecent
+		p.t1.pushFront(e)
+	}
+}
+
+func (p *arc) Get(e *Entry) {
+	if e.list == inFrequent {
+		p.t2.moveToFront(e)
+		return
+	}
+	p.t1.remove(e)
+	e.list = inFrequent
+	p.t2.pushFront(e)
+}
+
+func (p *arc) Remove(e *Entry) {
+	if e.list == inFrequent {
+		p.t2.remove(e)
+	} else {
+		p.t1.remove(e)
+	}
+}
+
+func (p *arc) Evict() *Entry {
+	var e *Entry
+	if p.t1.ll.Len() > 0 && (p.t1.bytes > p.p || p.t2.ll.Len() == 0) {
+		e = p.t1.back()
+		p.b1.push(e.Key, e.size)
+	} else if e = p.t2.back(); e != nil {
+		p.b2.push(e.Key, e.size)
+	}
+	return e
+}
+
+// 保持t1+b1不超过c，全部队列不超过2c
+func (p *arc) trim() {
+	if p.c == 0 {
+		return
+	}
+	p.b1.trim(max64(0, p.c-p.t1.bytes))
+	p.b2.trim(max64(0, 2*p.c-p.t1.bytes-p.t2.bytes-p.b1.bytes))
+}
+
+func (p *arc) Len() int {
+	return p.t1.ll.Len() + p.t2.ll.Len()
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
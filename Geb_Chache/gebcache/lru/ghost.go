@@ -0,0 +1,93 @@
+package lru
+
+import "container/list"
+
+// ghost 只记录key与大小、不保存值的LRU队列，ARC与2Q用它记住最近被淘汰的条目
+type ghost struct {
+	ll    *list.List
+	keys  map[string]*list.Element
+	bytes int64
+}
+
+type ghostEntry struct {
+	key  string
+	size int64
+}
+
+func newGhost() *ghost {
+	return &ghost{ll: list.New(), keys: make(map[string]*list.Element)}
+}
+
+func (g *ghost) push(key string, size int64) {
+	g.remove(key)
+	g.keys[key] = g.ll.PushFront(&ghostEntry{key: key, size: size})
+	g.bytes += size
+}
+
+// remove 返回key是否在队列中
+func (g *ghost) remove(key string) bool {
+	ele, ok := g.keys[key]
+	if !ok {
+		return false
+	}
+	g.ll.Remove(ele)
+	delete(g.keys, key)
+	g.bytes -= ele.Value.(*ghostEntry).size
+	return true
+}
+
+func (g *ghost) removeOldest() {
+	if ele := g.ll.Back(); ele != nil {
+		g.remove(ele.Value.(*ghostEntry).key)
+	}
+}
+
+// 队列的总字节数超过max时删除最旧的key
+func (g *ghost) trim(max int64) {
+	for g.bytes > max && g.ll.Len() > 0 {
+		g.removeOldest()
+	}
+}
+
+// sizedList 记录总字节数的条目队列，条目的size字段为加入时的大小
+type sizedList struct {
+	ll    *list.List
+	bytes int64
+}
+
+func newSizedList() *sizedList {
+	return &sizedList{ll: list.New()}
+}
+
+func (l *sizedList) pushFront(e *Entry) {
+	e.size = e.Size()
+	e.elem = l.ll.PushFront(e)
+	l.bytes += e.size
+}
+
+func (l *sizedList) remove(e *Entry) {
+	l.ll.Remove(e.elem)
+	l.bytes -= e.size
+}
+
+// 条目被访问或更新时移到队首，同时更新大小
+func (l *sizedList) moveToFront(e *Entry) {
+	size := e.Size()
+	l.bytes += size - e.size
+	e.size = size
+	l.ll.MoveToFront(e.elem)
+}
+
+func (l *sizedList) back() *Entry {
+	if ele := l.ll.Back(); ele != nil {
+		return ele.Value.(*Entry)
+	}
+	return nil
+}
+
+// 条目被更新时只更新大小，不改变位置
+func (l *sizedList) update(e *Entry) {
+	size := e.Size()
+	l.bytes += size - e.size
+	e.size = size
+}
@@ -0,0 +1,64 @@
+package lru
+
+import "container/list"
+
+type lfu struct {
+	// 按访问次数升序排列的桶，每个桶内按最近访问排列
+	buckets *list.List
+	n       int
+}
+
+type lfuBucket struct {
+	freq  int
+	items *list.List
+}
+
+// NewLFU 淘汰访问次数最少的条目，次数相同时淘汰最久未被访问的
+func NewLFU(maxBytes int64) Policy {
+	return &lfu{buckets: list.New()}
+}
+
+func (p *lfu) Add(e *Entry) {
+	front := p.buckets.Front()
+	if front == nil || front.Value.(*lfuBucket).freq != 1 {
+		front = p.buckets.PushFront(&lfuBucket{freq: 1, items: list.New()})
+	}
+	e.node = front
+	e.elem = front.Value.(*lfuBucket).items.PushFront(e)
+	p.n++
+}
+
+func (p *lfu) Get(e *Entry) {
+	cur := e.node
+	b := cur.Value.(*lfuBucket)
+	next := cur.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != b.freq+1 {
+		next = p.buckets.InsertAfter(&lfuBucket{freq: b.freq + 1, items: list.New()}, cur)
+	}
+	b.items.Remove(e.elem)
+	if b.items.Len() == 0 {
+		p.buckets.Remove(cur)
+	}
+	e.node = next
+	e.elem = next.Value.(*lfuBucket).items.PushFront(e)
+}
+
+func (p *lfu) Remove(e *Entry) {
+	b := e.node.Value.(*lfuBucket)
+	b.items.Remove(e.elem)
+	if b.items.Len() == 0 {
+		p.buckets.Remove(e.node)
+	}
+	p.n--
+}
+
+func (p *lfu) Evict() *Entry {
+	if front := p.buckets.Front(); front != nil {
+		return front.Value.(*lfuBucket).items.Back().Value.(*Entry)
+	}
+	return nil
+}
+
+func (p *lfu) Len() int {
+	return p.n
+}
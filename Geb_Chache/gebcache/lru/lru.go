@@ -1,77 +1,238 @@
 package lru
 
-import "container/list"
+import (
+	"container/heap"
+	"container/list"
+	"time"
+)
 
+// Cache 按字节数限制大小的缓存，淘汰哪个条目由Policy决定，默认为LRU；
+// Cache负责字节数、过期时间与淘汰回调，不是并发安全的
 type Cache struct {
 	maxBytes  int64
 	nbytes    int64
-	ll        *list.List
-	cache     map[string]*list.Element
+	cache     map[string]*Entry
+	policy    Policy
 	onEvicted func(key string, value Value)
+	// 设置了过期时间的条目，按过期时间排列的最小堆
+	expires expireHeap
+	// Now 返回当前时间，默认为time.Now
+	Now func() time.Time
 }
 
-type entry struct {
-	key   string
-	value Value
+// Policy 淘汰策略，Cache在条目加入、被访问与被删除时通知Policy，需要腾出空间时由Policy选择淘汰的条目
+type Policy interface {
+	// Add 加入一个新条目
+	Add(e *Entry)
+	// Get 条目被访问或被更新
+	Get(e *Entry)
+	// Remove 条目被删除，包括被淘汰、过期与显式删除
+	Remove(e *Entry)
+	// Evict 选择下一个淘汰的条目，返回刚加入的条目表示拒绝接纳它
+	Evict() *Entry
+	Len() int
+}
+
+// Entry 缓存中的一个条目
+type Entry struct {
+	Key   string
+	Value Value
+	// 零值表示不过期
+	expire time.Time
+	// 在expires中的下标，不在堆中时为-1
+	index int
+
+	// 以下字段由内置的策略使用
+	elem *list.Element
+	node *list.Element
+	list int
+	// 加入策略的队列时的大小，条目被更新后大小可能变化
+	size int64
+}
+
+// Size 条目占用的字节数
+func (e *Entry) Size() int64 {
+	return int64(len(e.Key) + e.Value.Len())
 }
 
 type Value interface {
 	Len() int
 }
 
+// New 使用LRU策略，maxBytes为0时不限制大小
 func New(maxBytes int64, onEvicted func(string2 string, value Value)) *Cache {
+	return NewWithPolicy(maxBytes, onEvicted, NewLRU(maxBytes))
+}
+
+func NewWithPolicy(maxBytes int64, onEvicted func(key string, value Value), policy Policy) *Cache {
 	return &Cache{
 		maxBytes:  maxBytes,
-		ll:        list.New(),
-		cache:     make(map[string]*list.Element),
+		cache:     make(map[string]*Entry),
+		policy:    policy,
 		onEvicted: onEvicted,
+		Now:       time.Now,
 	}
 }
 
+// Get 已过期的条目会在此时被删除
 func (c *Cache) Get(key string) (Value, bool) {
-	if ele, ok := c.cache[key]; ok {
-		c.ll.MoveToFront(ele)
-		kv := ele.Value.(*entry)
-		return kv.value, true
+	if e, ok := c.cache[key]; ok {
+		if !e.expire.IsZero() && !c.Now().Before(e.expire) {
+			c.removeEntry(e)
+			return nil, false
+		}
+		c.policy.Get(e)
+		return e.Value, true
 	}
 	return nil, false
 }
 
+// RemoveOldest 淘汰一个由Policy选择的条目
 func (c *Cache) RemoveOldest() {
-	ele := c.ll.Back()
-	if ele != nil {
-		c.ll.Remove(ele)
-		kv := ele.Value.(*entry)
-		delete(c.cache, kv.key)
-		c.nbytes -= int64(len(kv.key) + kv.value.Len())
-		if c.onEvicted != nil {
-			c.onEvicted(kv.key, kv.value)
-		}
+	if e := c.policy.Evict(); e != nil {
+		c.removeEntry(e)
 	}
 }
 
-func sizeOf(kv *entry) int64 {
-	return int64(len(kv.key) + kv.value.Len())
+// Remove 删除key，返回key是否存在
+func (c *Cache) Remove(key string) bool {
+	if e, ok := c.cache[key]; ok {
+		c.removeEntry(e)
+		return true
+	}
+	return false
+}
+
+// RemoveExpired 删除全部已过期的条目，返回删除的数量
+func (c *Cache) RemoveExpired() int {
+	now := c.Now()
+	n := 0
+	for len(c.expires) > 0 && !now.Before(c.expires[0].expire) {
+		c.removeEntry(c.expires[0])
+		n++
+	}
+	return n
+}
+
+func (c *Cache) removeEntry(e *Entry) {
+	c.policy.Remove(e)
+	delete(c.cache, e.Key)
+	if e.index >= 0 {
+		heap.Remove(&c.expires, e.index)
+	}
+	c.nbytes -= e.Size()
+	if c.onEvicted != nil {
+		c.onEvicted(e.Key, e.Value)
+	}
 }
 
 func (c *Cache) Add(key string, value Value) {
-	if ele, ok := c.cache[key]; ok {
-		c.ll.MoveToFront(ele)
-		kv := ele.Value.(*entry)
-		c.nbytes -= sizeOf(kv)
-		kv.value = value
-		c.nbytes += sizeOf(kv)
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire 添加一个在expire时过期的条目，expire为零值时不过期；
+// 已存在的条目会同时更新值与过期时间
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
+	if e, ok := c.cache[key]; ok {
+		c.nbytes -= e.Size()
+		e.Value = value
+		c.nbytes += e.Size()
+		c.setExpire(e, expire)
+		c.policy.Get(e)
 	} else {
-		e := &entry{key: key, value: value}
-		ele := c.ll.PushFront(e)
-		c.cache[key] = ele
-		c.nbytes += sizeOf(e)
+		e := &Entry{Key: key, Value: value, index: -1}
+		c.cache[key] = e
+		c.nbytes += e.Size()
+		c.setExpire(e, expire)
+		c.policy.Add(e)
+	}
+	if c.maxBytes != 0 && c.maxBytes < c.nbytes {
+		// 优先淘汰已过期的条目
+		c.RemoveExpired()
 	}
-	for c.maxBytes != 0 && c.maxBytes < c.nbytes {
+	for c.maxBytes != 0 && c.maxBytes < c.nbytes && len(c.cache) > 0 {
 		c.RemoveOldest()
 	}
 }
 
+func (c *Cache) setExpire(e *Entry, expire time.Time) {
+	e.expire = expire
+	switch {
+	case e.index >= 0 && expire.IsZero():
+		heap.Remove(&c.expires, e.index)
+	case e.index >= 0:
+		heap.Fix(&c.expires, e.index)
+	case !expire.IsZero():
+		heap.Push(&c.expires, e)
+	}
+}
+
 func (c *Cache) TotalElem() int {
-	return c.ll.Len()
+	return len(c.cache)
+}
+
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// Bytes 返回当前占用的字节数
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
+
+type lru struct {
+	ll *list.List
+}
+
+// NewLRU 淘汰最久未被访问的条目
+func NewLRU(maxBytes int64) Policy {
+	return &lru{ll: list.New()}
+}
+
+func (p *lru) Add(e *Entry) {
+	e.elem = p.ll.PushFront(e)
+}
+
+func (p *lru) Get(e *Entry) {
+	p.ll.MoveToFront(e.elem)
+}
+
+func (p *lru) Remove(e *Entry) {
+	p.ll.Remove(e.elem)
+}
+
+func (p *lru) Evict() *Entry {
+	if ele := p.ll.Back(); ele != nil {
+		return ele.Value.(*Entry)
+	}
+	return nil
+}
+
+func (p *lru) Len() int {
+	return p.ll.Len()
+}
+
+type expireHeap []*Entry
+
+func (h expireHeap) Len() int           { return len(h) }
+func (h expireHeap) Less(i, j int) bool { return h[i].expire.Before(h[j].expire) }
+func (h expireHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expireHeap) Push(x interface{}) {
+	e := x.(*Entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expireHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*h = old[:len(old)-1]
+	return e
 }
@@ -2,7 +2,10 @@ package lru
 
 import (
 	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strconv"
 	"testing"
+	"time"
 )
 
 type String string
@@ -55,3 +58,116 @@ func TestOnEvicted(t *testing.T) {
 
 	assert.Equal(t, 3, i)
 }
+
+func TestExpire(t *testing.T) {
+	now := time.Unix(1000, 0)
+	evicted := []string{}
+	lru := New(0, func(key string, value Value) {
+		evicted = append(evicted, key)
+	})
+	lru.Now = func() time.Time { return now }
+	lru.AddWithExpire("k1", String("v1"), now.Add(time.Second))
+	lru.AddWithExpire("k2", String("v2"), now.Add(3*time.Second))
+	lru.AddWithExpire("k3", String("v3"), now.Add(2*time.Second))
+	lru.Add("k4", String("v4"))
+
+	_, ok := lru.Get("k1")
+	assert.True(t, ok)
+
+	// 读取时删除
+	now = now.Add(time.Second)
+	_, ok = lru.Get("k1")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"k1"}, evicted)
+
+	// 更新过期时间
+	lru.AddWithExpire("k2", String("v2"), now.Add(10*time.Second))
+	now = now.Add(5 * time.Second)
+	assert.Equal(t, 1, lru.RemoveExpired())
+	assert.Equal(t, []string{"k1", "k3"}, evicted)
+	assert.Equal(t, 2, lru.TotalElem())
+
+	// 改为不过期
+	lru.Add("k2", String("v2"))
+	now = now.Add(time.Hour)
+	assert.Equal(t, 0, lru.RemoveExpired())
+	_, ok = lru.Get("k2")
+	assert.True(t, ok)
+	assert.Empty(t, lru.expires)
+}
+
+func TestExpireBeforeOldest(t *testing.T) {
+	now := time.Unix(1000, 0)
+	lru := New(12, nil)
+	lru.Now = func() time.Time { return now }
+	lru.Add("k1", String("v1"))
+	lru.AddWithExpire("k2", String("v2"), now.Add(time.Second))
+	lru.Add("k3", String("v3"))
+	now = now.Add(time.Second)
+	// 超出容量时先淘汰已过期的k2，而不是最久未使用的k1
+	lru.Add("k4", String("v4"))
+	_, ok := lru.Get("k1")
+	assert.True(t, ok)
+	assert.Equal(t, 3, lru.TotalElem())
+}
+
+func TestRemove(t *testing.T) {
+	var evicted []string
+	lru := New(0, func(key string, value Value) {
+		evicted = append(evicted, key)
+	})
+	lru.AddWithExpire("k1", String("v1"), time.Now().Add(time.Hour))
+	lru.Add("k2", String("v2"))
+	assert.True(t, lru.Remove("k1"))
+	assert.False(t, lru.Remove("k1"))
+	_, ok := lru.Get("k1")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"k1"}, evicted)
+	assert.Equal(t, 1, lru.TotalElem())
+	assert.Equal(t, int64(4), lru.nbytes)
+	assert.Empty(t, lru.expires)
+}
+
+// 服从Zipf分布的访问序列，s越大越集中在少数热点上
+func zipfTrace(s float64, keys uint64, n int) []string {
+	z := rand.NewZipf(rand.New(rand.NewSource(1)), s, 1, keys-1)
+	trace := make([]string, n)
+	for i := range trace {
+		trace[i] = strconv.FormatUint(z.Uint64(), 10)
+	}
+	return trace
+}
+
+// 在Zipf分布的访问序列上比较各策略的命中率，未命中时加入缓存，
+// 结果以hit-ratio指标输出：go test -bench HitRatio ./lru
+func BenchmarkHitRatio(b *testing.B) {
+	const keys = 100000
+	traces := []struct {
+		name  string
+		trace []string
+	}{
+		{"zipf1.01", zipfTrace(1.01, keys, 200000)},
+		{"zipf1.2", zipfTrace(1.2, keys, 200000)},
+	}
+	for _, tr := range traces {
+		// 条目约16字节，缓存能容纳约1%的key
+		const maxBytes = keys / 100 * 16
+		for _, p := range policies {
+			b.Run(tr.name+"/"+p.name, func(b *testing.B) {
+				var hits, total int
+				for i := 0; i < b.N; i++ {
+					c := NewWithPolicy(maxBytes, nil, p.new(maxBytes))
+					for _, key := range tr.trace {
+						if _, ok := c.Get(key); ok {
+							hits++
+						} else {
+							c.Add(key, String("0123456789"))
+						}
+					}
+					total += len(tr.trace)
+				}
+				b.ReportMetric(float64(hits)/float64(total), "hit-ratio")
+			})
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package lru
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+var policies = []struct {
+	name string
+	new  func(maxBytes int64) Policy
+}{
+	{"LRU", NewLRU},
+	{"LFU", NewLFU},
+	{"2Q", New2Q},
+	{"ARC", NewARC},
+	{"TinyLFU", NewTinyLFU},
+}
+
+// 随机的读、写、删除与过期之后，字节数与条目数仍然一致
+func TestPolicyInvariants(t *testing.T) {
+	for _, p := range policies {
+		t.Run(p.name, func(t *testing.T) {
+			now := time.Unix(1000, 0)
+			var evicted int
+			c := NewWithPolicy(200, func(string, Value) { evicted++ }, p.new(200))
+			c.Now = func() time.Time { return now }
+			r := rand.New(rand.NewSource(1))
+			for i := 0; i < 20000; i++ {
+				key := fmt.Sprintf("k%d", r.Intn(100))
+				switch op := r.Intn(10); {
+				case op < 5:
+					c.Get(key)
+				case op < 8:
+					c.Add(key, String(make([]byte, r.Intn(20))))
+				case op < 9:
+					c.AddWithExpire(key, String("v"), now.Add(time.Duration(r.Intn(5))*time.Second))
+				default:
+					c.Remove(key)
+				}
+				now = now.Add(100 * time.Millisecond)
+
+				assert.LessOrEqual(t, c.nbytes, int64(200))
+				assert.Equal(t, c.Len(), c.policy.Len())
+			}
+			var total int64
+			for _, e := range c.cache {
+				total += e.Size()
+			}
+			assert.Equal(t, total, c.nbytes)
+			assert.Greater(t, evicted, 0)
+		})
+	}
+}
+
+// 只访问一次的扫描之后，被多次访问的key仍在缓存中
+func TestScanResistance(t *testing.T) {
+	for _, p := range policies {
+		if p.name == "LRU" {
+			continue
+		}
+		t.Run(p.name, func(t *testing.T) {
+			c := NewWithPolicy(1000, nil, p.new(1000))
+			get := func(key string) {
+				if _, ok := c.Get(key); !ok {
+					c.Add(key, String("12345"))
+				}
+			}
+			// 每个条目10字节，可以容纳100个；热点key与一次性的key交替访问
+			n := 0
+			for round := 0; round < 10; round++ {
+				for i := 0; i < 20; i++ {
+					get(fmt.Sprintf("hot%02d", i))
+				}
+				for i := 0; i < 50; i++ {
+					get(fmt.Sprintf("s%04d", n))
+					n++
+				}
+			}
+			// 长扫描
+			for i := 0; i < 1000; i++ {
+				get(fmt.Sprintf("s%04d", n))
+				n++
+			}
+			hits := 0
+			for i := 0; i < 20; i++ {
+				if _, ok := c.Get(fmt.Sprintf("hot%02d", i)); ok {
+					hits++
+				}
+			}
+			assert.Equal(t, 20, hits)
+		})
+	}
+}
+
+func TestLFU(t *testing.T) {
+	c := NewWithPolicy(6, nil, NewLFU(6))
+	c.Add("a", String("1"))
+	c.Add("b", String("1"))
+	c.Add("c", String("1"))
+	c.Get("a")
+	c.Get("a")
+	c.Get("c")
+	// b的访问次数最少
+	c.Add("d", String("1"))
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	// 新加入的条目访问次数最少，缓存满时立即被淘汰
+	c.Get("d")
+	c.Add("e", String("1"))
+	_, ok = c.Get("e")
+	assert.False(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, c.Len())
+}
+
+func Test2Q(t *testing.T) {
+	c := NewWithPolicy(8, nil, New2Q(8))
+	for _, k := range []string{"a", "b", "c", "d"} {
+		c.Add(k, String("1"))
+	}
+	// a从A1in淘汰后记录在A1out中，再次加入时进入Am
+	c.Add("e", String("1"))
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	c.Add("a", String("1"))
+	assert.Equal(t, inFrequent, c.cache["a"].list)
+	assert.Equal(t, inRecent, c.cache["e"].list)
+}
+
+func TestARC(t *testing.T) {
+	p := NewARC(8).(*arc)
+	c := NewWithPolicy(8, nil, p)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		c.Add(k, String("1"))
+	}
+	c.Get("a")
+	assert.Equal(t, inFrequent, c.cache["a"].list)
+	// t1超过目标大小，淘汰t1中最旧的b
+	c.Add("e", String("1"))
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, int64(0), p.p)
+	// 命中b1，增大t1的目标大小
+	c.Add("b", String("1"))
+	assert.Equal(t, int64(2), p.p)
+	assert.Equal(t, inFrequent, c.cache["b"].list)
+}
+
+func TestCMSketch(t *testing.T) {
+	s := newCMSketch(0)
+	for i := 0; i < 20; i++ {
+		s.add(hashKey("hot"))
+	}
+	s.add(hashKey("cold"))
+	assert.Equal(t, uint8(maxCount), s.estimate(hashKey("hot")))
+	assert.Equal(t, uint8(1), s.estimate(hashKey("cold")))
+	assert.Equal(t, uint8(0), s.estimate(hashKey("none")))
+
+	s.reset()
+	assert.Equal(t, uint8(maxCount/2), s.estimate(hashKey("hot")))
+	assert.Equal(t, uint8(0), s.estimate(hashKey("cold")))
+}
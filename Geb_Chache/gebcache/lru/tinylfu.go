@@ -0,0 +1,176 @@
+package lru
+
+import "hash/fnv"
+
+type tinyLFU struct {
+	// 新条目先进入窗口LRU，窗口满后与主区probation队尾的条目比较访问频率，频率高的留下
+	window       *sizedList
+	probation    *sizedList
+	protected    *sizedList
+	windowMax    int64
+	mainMax      int64
+	protectedMax int64
+	sketch       *cmSketch
+}
+
+// NewTinyLFU W-TinyLFU策略：1%的窗口LRU吸收突发的新条目，其余为分段LRU，
+// 新条目能否进入主区由count-min sketch估计的访问频率决定，扫描与一次性访问不会冲掉热点
+func NewTinyLFU(maxBytes int64) Policy {
+	windowMax := maxBytes / 100
+	protectedMax := (maxBytes - windowMax) * 8 / 10
+	if maxBytes == 0 {
+		protectedMax = 1<<63 - 1
+	}
+	return &tinyLFU{
+		window:       newSizedList(),
+		probation:    newSizedList(),
+		protected:    newSizedList(),
+		windowMax:    windowMax,
+		mainMax:      maxBytes - windowMax,
+		protectedMax: protectedMax,
+		// 按平均每个条目64字节估计条目数
+		sketch: newCMSketch(maxBytes / 64),
+	}
+}
+
+func (p *tinyLFU) Add(e *Entry) {
+	p.sketch.add(hashKey(e.Key))
+	e.list = inWindow
+	p.window.pushFront(e)
+	// 主区还有空间时窗口溢出的条目直接进入probation，主区满后由Evict决定是否接纳
+	for p.window.bytes > p.windowMax && p.window.ll.Len() > 1 {
+		candidate := p.window.back()
+		if p.mainMax != 0 && p.probation.bytes+p.protected.bytes+candidate.size > p.mainMax {
+			break
+		}
+		p.window.remove(candidate)
+		candidate.list = inProbation
+		p.probation.pushFront(candidate)
+	}
+}
+
+func (p *tinyLFU) Get(e *Entry) {
+	p.sketch.add(hashKey(e.Key))
+	switch e.list {
+	case inWindow:
+		p.window.moveToFront(e)
+	case inProtected:
+		p.protected.moveToFront(e)
+	case inProbation:
+		p.probation.remove(e)
+		e.list = inProtected
+		p.protected.pushFront(e)
+		for p.protected.bytes > p.protectedMax && p.protected.ll.Len() > 1 {
+			demoted := p.protected.back()
+			p.protected.remove(demoted)
+			demoted.list = inProbation
+			p.probation.pushFront(demoted)
+		}
+	}
+}
+
+func (p *tinyLFU) Remove(e *Entry) {
+	p.queue(e).remove(e)
+}
+
+func (p *tinyLFU) queue(e *Entry) *sizedList {
+	switch e.list {
+	case inWindow:
+		return p.window
+	case inProbation:
+		return p.probation
+	}
+	return p.protected
+}
+
+func (p *tinyLFU) Evict() *Entry {
+	victim := p.probation.back()
+	if victim == nil {
+		victim = p.protected.back()
+	}
+	if p.window.bytes <= p.windowMax && victim != nil {
+		return victim
+	}
+	candidate := p.window.back()
+	if candidate == nil || victim == nil {
+		return candidate
+	}
+	// 窗口已满，淘汰候选者与主区的淘汰对象中访问频率低的一个
+	if p.sketch.estimate(hashKey(candidate.Key)) <= p.sketch.estimate(hashKey(victim.Key)) {
+		return candidate
+	}
+	p.window.remove(candidate)
+	candidate.list = inProbation
+	p.probation.pushFront(candidate)
+	return victim
+}
+
+func (p *tinyLFU) Len() int {
+	return p.window.ll.Len() + p.probation.ll.Len() + p.protected.ll.Len()
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// cmSketch 4行4位计数器的count-min sketch，估计key最近的访问次数；
+// 计数次数达到上限后所有计数器减半，使旧的访问逐渐失去权重
+type cmSketch struct {
+	rows      [4][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+const maxCount = 15
+
+func newCMSketch(entries int64) *cmSketch {
+	width := int64(256)
+	for width < entries && width < 1<<20 {
+		width <<= 1
+	}
+	s := &cmSketch{mask: uint64(width - 1), resetAt: int(width) * 10}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// 用双重哈希得到每一行的下标
+func (s *cmSketch) index(h uint64, i int) uint64 {
+	h1, h2 := h&0xffffffff, h>>32
+	return (h1 + uint64(i)*h2) & s.mask
+}
+
+func (s *cmSketch) add(h uint64) {
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < maxCount {
+			*c++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *cmSketch) estimate(h uint64) uint8 {
+	min := uint8(maxCount)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
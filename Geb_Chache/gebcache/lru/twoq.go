@@ -0,0 +1,75 @@
+package lru
+
+// 条目所在的队列
+const (
+	inRecent = iota + 1
+	inFrequent
+	inWindow
+	inProbation
+	inProtected
+)
+
+type twoQueue struct {
+	// 只被访问过一次的条目，先进先出
+	in *sizedList
+	// 从in淘汰的key
+	out *ghost
+	// 被访问过多次的条目，LRU
+	main   *sizedList
+	inMax  int64
+	outMax int64
+}
+
+// New2Q 新条目先进入先进先出的A1in队列，在A1in中被淘汰后不久再次加入的条目才进入LRU的Am队列，
+// 只访问一次的扫描不会冲掉Am中的热点；A1in占总大小的1/4，记录淘汰历史的A1out占1/2
+func New2Q(maxBytes int64) Policy {
+	return &twoQueue{
+		in:     newSizedList(),
+		out:    newGhost(),
+		main:   newSizedList(),
+		inMax:  maxBytes / 4,
+		outMax: maxBytes / 2,
+	}
+}
+
+func (p *twoQueue) Add(e *Entry) {
+	if p.out.remove(e.Key) {
+		e.list = inFrequent
+		p.main.pushFront(e)
+		return
+	}
+	e.list = inRecent
+	p.in.pushFront(e)
+}
+
+func (p *twoQueue) Get(e *Entry) {
+	if e.list == inFrequent {
+		p.main.moveToFront(e)
+		return
+	}
+	// A1in中的条目被访问时不调整位置
+	p.in.update(e)
+}
+
+func (p *twoQueue) Remove(e *Entry) {
+	if e.list == inFrequent {
+		p.main.remove(e)
+	} else {
+		p.in.remove(e)
+	}
+}
+
+func (p *twoQueue) Evict() *Entry {
+	if p.in.bytes > p.inMax || p.main.ll.Len() == 0 {
+		if e := p.in.back(); e != nil {
+			p.out.push(e.Key, e.size)
+			p.out.trim(p.outMax)
+			return e
+		}
+	}
+	return p.main.back()
+}
+
+func (p *twoQueue) Len() int {
+	return p.in.ll.Len() + p.main.ll.Len()
+}
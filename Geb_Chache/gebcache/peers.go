@@ -1,6 +1,18 @@
 package gebcache
 
+import "time"
+
 // 从对端获取数据
 type PeerGetter interface {
 	Get(group, key string) ([]byte, error)
 }
+
+// PeerUpdater 修改对端的缓存，Peers同时实现该接口时，Group.Set与Group.Remove会转发到key所属的节点
+type PeerUpdater interface {
+	// Set 将值写入key所属节点的缓存，key属于本节点时不做任何事并返回false
+	Set(group, key string, value []byte, ttl time.Duration) (remote bool, err error)
+	// Remove 从key所属节点的缓存中删除key，key属于本节点时不做任何事并返回false
+	Remove(group, key string) (remote bool, err error)
+	// Purge 从本节点与key所属节点之外的全部节点删除key，用于清除这些节点上的副本
+	Purge(group, key string) error
+}
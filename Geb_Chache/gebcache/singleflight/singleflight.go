@@ -39,6 +39,32 @@ func (g *Group) Do(key string, f func() (interface{}, error)) (interface{}, erro
 	return c.val, c.err
 }
 
+// Start key没有正在执行的调用时，在新的goroutine中执行f并返回true，
+// 执行期间对同一个key的Do会等待该调用的结果；否则不做任何事并返回false
+func (g *Group) Start(key string, f func() (interface{}, error)) bool {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if _, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		return false
+	}
+	c := new(call)
+	g.m[key] = c
+	c.wg.Add(1)
+	g.mu.Unlock()
+	go func() {
+		c.val, c.err = f()
+		c.wg.Done()
+
+		g.mu.Lock()
+		delete(g.m, key)
+		g.mu.Unlock()
+	}()
+	return true
+}
+
 func New() *Group {
 	return &Group{}
 }
@@ -0,0 +1,40 @@
+// Package auth 认证中间件：HTTP Basic、Bearer JWT（HS256）与cookie会话
+//
+// 认证成功后主体保存在Context中，可以通过User、GetClaims、GetSession获取
+package auth
+
+import (
+	"geb"
+	"net/http"
+)
+
+var (
+	// UserKey 认证得到的用户名：Basic中为用户名，JWT中为sub声明，会话中为SessionAuth指定的字段
+	UserKey = geb.NewKey[string]("auth.user")
+	// ClaimsKey Bearer认证通过后的JWT声明
+	ClaimsKey = geb.NewKey[Claims]("auth.claims")
+	// SessionKey Sessions中间件加载的会话
+	SessionKey = geb.NewKey[*Session]("auth.session")
+)
+
+// User 返回认证得到的用户名，未认证时返回空字符串
+func User(c *geb.Context) string {
+	u, _ := UserKey.Get(c)
+	return u
+}
+
+// GetClaims 返回Bearer认证通过后的JWT声明
+func GetClaims(c *geb.Context) (Claims, bool) {
+	return ClaimsKey.Get(c)
+}
+
+// GetSession 返回当前请求的会话，没有使用Sessions中间件时返回nil
+func GetSession(c *geb.Context) *Session {
+	s, _ := SessionKey.Get(c)
+	return s
+}
+
+func unauthorized(c *geb.Context, challenge string) {
+	c.SetHeader("WWW-Authenticate", challenge)
+	c.AbortWithStatus(http.StatusUnauthorized)
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"geb"
+	"strconv"
+)
+
+// BasicAuth 使用用户名到密码的映射进行HTTP Basic认证，失败时返回401
+func BasicAuth(users map[string]string, realm string) geb.HandlerFunc {
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm)
+	// 保存摘要，比较时耗时与密码长度无关
+	digests := make(map[string][32]byte, len(users))
+	for u, p := range users {
+		digests[u] = sha256.Sum256([]byte(p))
+	}
+	return func(c *geb.Context) {
+		user, pass, ok := c.Req.BasicAuth()
+		if !ok {
+			unauthorized(c, challenge)
+			return
+		}
+		want, has := digests[user]
+		got := sha256.Sum256([]byte(pass))
+		if subtle.ConstantTimeCompare(want[:], got[:]) != 1 || !has {
+			unauthorized(c, challenge)
+			return
+		}
+		UserKey.Set(c, user)
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"geb"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	e := geb.New()
+	e.Use(BasicAuth(map[string]string{"weiwei": "123"}, "admin"))
+	e.GET("/me", func(c *geb.Context) {
+		c.Text(http.StatusOK, User(c))
+	})
+	req := httptest.NewRequest("GET", "/me", nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Basic realm="admin"`, w.Header().Get("WWW-Authenticate"))
+
+	for _, c := range [][2]string{{"weiwei", "1234"}, {"feifei", "123"}} {
+		req = httptest.NewRequest("GET", "/me", nil)
+		req.SetBasicAuth(c[0], c[1])
+		w = httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/me", nil)
+	req.SetBasicAuth("weiwei", "123")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "weiwei", w.Body.String())
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"geb"
+	"strings"
+	"time"
+)
+
+// Claims JWT声明
+type Claims map[string]interface{}
+
+// Subject 返回sub声明
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+// 返回数字类型的时间声明，例如exp、nbf、iat；声明不存在时ok为false，存在但不是数字时返回ErrTokenMalformed
+func (c Claims) time(name string) (t time.Time, ok bool, err error) {
+	v, ok := c[name]
+	if !ok {
+		return
+	}
+	switch v := v.(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true, nil
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return time.Unix(int64(f), 0), true, nil
+		}
+	}
+	return time.Time{}, true, ErrTokenMalformed
+}
+
+func (c Claims) hasAudience(aud string) bool {
+	switch v := c["aud"].(type) {
+	case string:
+		return v == aud
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == aud {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	ErrTokenMalformed = errors.New("token格式错误")
+	ErrTokenSignature = errors.New("token签名错误")
+	ErrTokenExpired   = errors.New("token已过期")
+	ErrTokenNotValid  = errors.New("token尚未生效")
+)
+
+// JWTConfig Bearer认证配置
+type JWTConfig struct {
+	// Secret HS256的密钥
+	Secret []byte
+	// Issuer 不为空时要求iss声明与之相同
+	Issuer string
+	// Audience 不为空时要求aud声明包含该值
+	Audience string
+	// Leeway 校验exp与nbf时允许的时钟误差
+	Leeway time.Duration
+	// Validate 额外的声明校验
+	Validate func(Claims) error
+}
+
+// SignHS256 使用HS256签发一个token
+func SignHS256(claims Claims, secret []byte) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signing := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sign(signing, secret)), nil
+}
+
+func sign(signing string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signing))
+	return mac.Sum(nil)
+}
+
+// ParseHS256 校验token的签名与声明，只接受alg为HS256的token
+func ParseHS256(token string, conf JWTConfig) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if header.Alg != "HS256" {
+		return nil, fmt.Errorf("不支持的算法%q", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if !hmac.Equal(sig, sign(parts[0]+"."+parts[1], conf.Secret)) {
+		return nil, ErrTokenSignature
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	now := time.Now()
+	exp, ok, err := claims.time("exp")
+	if err != nil {
+		return nil, err
+	}
+	if ok && !now.Before(exp.Add(conf.Leeway)) {
+		return nil, ErrTokenExpired
+	}
+	nbf, ok, err := claims.time("nbf")
+	if err != nil {
+		return nil, err
+	}
+	if ok && now.Add(conf.Leeway).Before(nbf) {
+		return nil, ErrTokenNotValid
+	}
+	if conf.Issuer != "" && claims["iss"] != conf.Issuer {
+		return nil, fmt.Errorf("iss不匹配")
+	}
+	if conf.Audience != "" && !claims.hasAudience(conf.Audience) {
+		return nil, fmt.Errorf("aud不匹配")
+	}
+	if conf.Validate != nil {
+		if err := conf.Validate(claims); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// Bearer 从Authorization: Bearer <token>中读取JWT并校验，失败时返回401
+func Bearer(conf JWTConfig) geb.HandlerFunc {
+	if len(conf.Secret) == 0 {
+		panic("auth: jwt secret is required")
+	}
+	return func(c *geb.Context) {
+		h := c.Req.Header.Get("Authorization")
+		if len(h) < 7 || !strings.EqualFold(h[:7], "Bearer ") {
+			unauthorized(c, `Bearer`)
+			return
+		}
+		claims, err := ParseHS256(strings.TrimSpace(h[7:]), conf)
+		if err != nil {
+			unauthorized(c, `Bearer error="invalid_token"`)
+			return
+		}
+		ClaimsKey.Set(c, claims)
+		if sub := claims.Subject(); sub != "" {
+			UserKey.Set(c, sub)
+		}
+	}
+}
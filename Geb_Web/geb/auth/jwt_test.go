@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"encoding/base64"
+	"geb"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var secret = []byte("secret")
+
+func TestParseHS256(t *testing.T) {
+	conf := JWTConfig{Secret: secret, Issuer: "geb", Audience: "api"}
+	now := time.Now().Unix()
+	token, err := SignHS256(Claims{"sub": "weiwei", "iss": "geb", "aud": []string{"web", "api"}, "exp": now + 60}, secret)
+	assert.Nil(t, err)
+	claims, err := ParseHS256(token, conf)
+	assert.Nil(t, err)
+	assert.Equal(t, "weiwei", claims.Subject())
+
+	_, err = ParseHS256(token, JWTConfig{Secret: []byte("other")})
+	assert.Equal(t, ErrTokenSignature, err)
+
+	token, _ = SignHS256(Claims{"exp": now - 10}, secret)
+	_, err = ParseHS256(token, JWTConfig{Secret: secret})
+	assert.Equal(t, ErrTokenExpired, err)
+	_, err = ParseHS256(token, JWTConfig{Secret: secret, Leeway: time.Minute})
+	assert.Nil(t, err)
+
+	token, _ = SignHS256(Claims{"nbf": now + 60}, secret)
+	_, err = ParseHS256(token, JWTConfig{Secret: secret})
+	assert.Equal(t, ErrTokenNotValid, err)
+
+	token, _ = SignHS256(Claims{"iss": "other", "aud": "api"}, secret)
+	_, err = ParseHS256(token, conf)
+	assert.Error(t, err)
+
+	// 拒绝alg为none的token
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"root"}`))
+	_, err = ParseHS256(header+"."+payload+".", JWTConfig{Secret: secret})
+	assert.Error(t, err)
+
+	_, err = ParseHS256("a.b", conf)
+	assert.Equal(t, ErrTokenMalformed, err)
+
+	// exp与nbf存在但不是数字时不能跳过校验
+	for _, c := range []Claims{{"exp": "1"}, {"nbf": "9999999999"}, {"exp": nil}, {"exp": true}, {"nbf": []int{1}}} {
+		token, _ = SignHS256(c, secret)
+		_, err = ParseHS256(token, JWTConfig{Secret: secret})
+		assert.Equal(t, ErrTokenMalformed, err, c)
+	}
+}
+
+func TestBearer(t *testing.T) {
+	e := geb.New()
+	e.Use(Bearer(JWTConfig{Secret: secret, Validate: func(c Claims) error {
+		if c["role"] != "admin" {
+			return ErrTokenNotValid
+		}
+		return nil
+	}}))
+	e.GET("/me", func(c *geb.Context) {
+		claims, _ := GetClaims(c)
+		c.Text(http.StatusOK, User(c)+":"+claims["role"].(string))
+	})
+	do := func(auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/me", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+	w := do("")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+
+	token, _ := SignHS256(Claims{"sub": "weiwei", "role": "user"}, secret)
+	w = do("Bearer " + token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.True(t, strings.Contains(w.Header().Get("WWW-Authenticate"), "invalid_token"))
+
+	token, _ = SignHS256(Claims{"sub": "weiwei", "role": "admin"}, secret)
+	w = do("bearer " + token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "weiwei:admin", w.Body.String())
+}
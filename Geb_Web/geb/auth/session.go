@@ -0,0 +1,346 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"geb"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store 会话存储，cookie的值由Store决定：可以是会话ID，也可以是会话数据本身
+type Store interface {
+	// Load 根据cookie的值加载会话数据，cookie无效或会话不存在时返回nil, nil
+	Load(cookie string) (map[string]string, error)
+	// Save 保存会话数据，返回写入cookie的新值
+	Save(cookie string, values map[string]string, maxAge time.Duration) (string, error)
+	// Delete 删除会话
+	Delete(cookie string) error
+}
+
+var errInvalidCookie = errors.New("会话cookie无效")
+
+// MemoryStore 会话数据保存在内存中，cookie中是带HMAC签名的随机ID
+type MemoryStore struct {
+	secret []byte
+
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+}
+
+// MemoryStore清理过期会话的间隔
+const memorySweepInterval = time.Minute
+
+type memorySession struct {
+	values  map[string]string
+	expires time.Time
+}
+
+// NewMemoryStore secret用于签名会话ID
+func NewMemoryStore(secret []byte) *MemoryStore {
+	return &MemoryStore{secret: secret, sessions: make(map[string]memorySession)}
+}
+
+func (s *MemoryStore) sign(id string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *MemoryStore) verify(cookie string) (string, bool) {
+	i := strings.LastIndex(cookie, ".")
+	if i < 0 {
+		return "", false
+	}
+	id := cookie[:i]
+	return id, hmac.Equal([]byte(cookie[i+1:]), []byte(s.sign(id)))
+}
+
+func (s *MemoryStore) Load(cookie string) (map[string]string, error) {
+	id, ok := s.verify(cookie)
+	if !ok {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms, ok := s.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(ms.expires) {
+		delete(s.sessions, id)
+		return nil, nil
+	}
+	return copyValues(ms.values), nil
+}
+
+// Save 只沿用仍然存在且未过期的会话ID，其他情况下生成新的ID，
+// 签名有效但已过期、已删除的ID不会被重新启用，防止会话固定攻击
+func (s *MemoryStore) Save(cookie string, values map[string]string, maxAge time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	id, ok := s.verify(cookie)
+	if ok {
+		_, ok = s.sessions[id]
+	}
+	if !ok {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		id = hex.EncodeToString(b)
+	}
+	s.sessions[id] = memorySession{values: copyValues(values), expires: now.Add(maxAge)}
+	return id + "." + s.sign(id), nil
+}
+
+// 每隔memorySweepInterval清除一次过期的会话，调用方需持有锁
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for k, ms := range s.sessions {
+		if now.After(ms.expires) {
+			delete(s.sessions, k)
+		}
+	}
+}
+
+func (s *MemoryStore) Delete(cookie string) error {
+	if id, ok := s.verify(cookie); ok {
+		s.mu.Lock()
+		delete(s.sessions, id)
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// CookieStore 会话数据以AES-GCM加密后保存在cookie中，服务端不保存状态；
+// 密文中包含过期时间，过期或被篡改的cookie视为空会话，Delete只能清除cookie本身
+type CookieStore struct {
+	aead cipher.AEAD
+}
+
+// NewCookieStore key的长度必须是16、24或32字节
+func NewCookieStore(key []byte) (*CookieStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieStore{aead: aead}, nil
+}
+
+type cookiePayload struct {
+	Values  map[string]string `json:"v"`
+	Expires int64             `json:"e"`
+}
+
+func (s *CookieStore) Load(cookie string) (map[string]string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cookie)
+	n := s.aead.NonceSize()
+	if err != nil || len(data) < n {
+		return nil, nil
+	}
+	plain, err := s.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return nil, nil
+	}
+	var p cookiePayload
+	if err := json.Unmarshal(plain, &p); err != nil {
+		return nil, nil
+	}
+	if time.Now().Unix() > p.Expires {
+		return nil, nil
+	}
+	return p.Values, nil
+}
+
+func (s *CookieStore) Save(_ string, values map[string]string, maxAge time.Duration) (string, error) {
+	plain, err := json.Marshal(cookiePayload{Values: values, Expires: time.Now().Add(maxAge).Unix()})
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := s.aead.Seal(nonce, nonce, plain, nil)
+	value := base64.RawURLEncoding.EncodeToString(data)
+	// 浏览器对单个cookie的限制约为4KB
+	if len(value) > 4000 {
+		return "", fmt.Errorf("会话数据过大")
+	}
+	return value, nil
+}
+
+func (s *CookieStore) Delete(string) error {
+	return nil
+}
+
+// SessionConfig 会话中间件配置
+type SessionConfig struct {
+	// Name cookie名，默认为geb_session
+	Name  string
+	Store Store
+	// MaxAge 会话有效期，默认24小时
+	MaxAge   time.Duration
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// Session 一个请求的会话，修改后需要调用Save才会写入cookie，
+// 因此Save必须在写出响应之前调用
+type Session struct {
+	c      *geb.Context
+	conf   *SessionConfig
+	cookie string
+	values map[string]string
+	isNew  bool
+}
+
+// Sessions 加载会话并保存到Context中，通过GetSession获取
+func Sessions(conf SessionConfig) geb.HandlerFunc {
+	if conf.Store == nil {
+		panic("auth: session store is required")
+	}
+	if conf.Name == "" {
+		conf.Name = "geb_session"
+	}
+	if conf.MaxAge <= 0 {
+		conf.MaxAge = 24 * time.Hour
+	}
+	if conf.Path == "" {
+		conf.Path = "/"
+	}
+	if conf.SameSite == 0 {
+		conf.SameSite = http.SameSiteLaxMode
+	}
+	return func(c *geb.Context) {
+		s := &Session{c: c, conf: &conf}
+		if ck, err := c.Req.Cookie(conf.Name); err == nil {
+			s.cookie = ck.Value
+			values, err := conf.Store.Load(ck.Value)
+			if err != nil {
+				c.Error(err)
+				c.Abort()
+				return
+			}
+			s.values = values
+		}
+		if s.values == nil {
+			s.values = make(map[string]string)
+			s.isNew = true
+		}
+		SessionKey.Set(c, s)
+	}
+}
+
+// SessionAuth 要求会话中存在field字段，并将其作为用户名保存到UserKey，否则返回401；
+// 需要注册在Sessions之后
+func SessionAuth(field string) geb.HandlerFunc {
+	return func(c *geb.Context) {
+		s := GetSession(c)
+		if s == nil {
+			c.Error(errors.New("auth: SessionAuth需要在Sessions之后注册"))
+			c.Abort()
+			return
+		}
+		user := s.Get(field)
+		if user == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		UserKey.Set(c, user)
+	}
+}
+
+func (s *Session) Get(key string) string {
+	return s.values[key]
+}
+
+func (s *Session) Set(key, value string) {
+	s.values[key] = value
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.values, key)
+}
+
+// IsNew 请求中没有有效的会话时为true
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// Save 保存会话并写入Set-Cookie
+func (s *Session) Save() error {
+	value, err := s.conf.Store.Save(s.cookie, s.values, s.conf.MaxAge)
+	if err != nil {
+		return err
+	}
+	s.cookie = value
+	s.isNew = false
+	s.setCookie(value, int(s.conf.MaxAge/time.Second))
+	return nil
+}
+
+// Regenerate 删除旧的会话，以新的会话ID保存当前数据并写入Set-Cookie；
+// 登录等权限变化之后调用，使登录前可能泄露的会话ID失效
+func (s *Session) Regenerate() error {
+	if s.cookie != "" {
+		if err := s.conf.Store.Delete(s.cookie); err != nil {
+			return err
+		}
+		s.cookie = ""
+	}
+	return s.Save()
+}
+
+// Destroy 删除会话并清除cookie
+func (s *Session) Destroy() error {
+	err := s.conf.Store.Delete(s.cookie)
+	s.values = make(map[string]string)
+	s.cookie = ""
+	s.setCookie("", -1)
+	return err
+}
+
+func (s *Session) setCookie(value string, maxAge int) {
+	http.SetCookie(s.c.Writer, &http.Cookie{
+		Name:     s.conf.Name,
+		Value:    value,
+		Path:     s.conf.Path,
+		Domain:   s.conf.Domain,
+		MaxAge:   maxAge,
+		Secure:   s.conf.Secure,
+		HttpOnly: true,
+		SameSite: s.conf.SameSite,
+	})
+}
+
+func copyValues(values map[string]string) map[string]string {
+	m := make(map[string]string, len(values))
+	for k, v := range values {
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"geb"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func sessionEngine(store Store) *geb.Engine {
+	e := geb.New()
+	e.Use(Sessions(SessionConfig{Store: store, MaxAge: time.Hour}))
+	e.POST("/login", func(c *geb.Context) {
+		s := GetSession(c)
+		s.Set("user", c.Req.URL.Query().Get("user"))
+		if err := s.Regenerate(); err != nil {
+			c.Error(err)
+			return
+		}
+		c.Text(http.StatusOK, "ok")
+	})
+	e.POST("/logout", func(c *geb.Context) {
+		GetSession(c).Destroy()
+	})
+	private := e.Group("/private", SessionAuth("user"))
+	private.GET("/me", func(c *geb.Context) {
+		c.Text(http.StatusOK, User(c))
+	})
+	return e
+}
+
+func testSessionFlow(t *testing.T, store Store) {
+	e := sessionEngine(store)
+	do := func(method, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for _, ck := range cookies {
+			req.AddCookie(ck)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/private/me", nil).Code)
+
+	w := do("POST", "/login?user=weiwei", nil)
+	cookies := w.Result().Cookies()
+	if !assert.Equal(t, 1, len(cookies)) {
+		return
+	}
+	assert.Equal(t, "geb_session", cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Equal(t, 3600, cookies[0].MaxAge)
+
+	w = do("GET", "/private/me", cookies)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "weiwei", w.Body.String())
+
+	tampered := *cookies[0]
+	tampered.Value = tampered.Value[:len(tampered.Value)-2] + "xx"
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/private/me", []*http.Cookie{&tampered}).Code)
+
+	// 再次登录时更换会话ID
+	w = do("POST", "/login?user=other", cookies)
+	relogin := w.Result().Cookies()
+	assert.NotEqual(t, cookies[0].Value, relogin[0].Value)
+	assert.Equal(t, "other", do("GET", "/private/me", relogin).Body.String())
+
+	w = do("POST", "/logout", relogin)
+	assert.Equal(t, -1, w.Result().Cookies()[0].MaxAge)
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore([]byte("secret"))
+	testSessionFlow(t, store)
+
+	cookie, err := store.Save("", map[string]string{"a": "1"}, time.Hour)
+	assert.Nil(t, err)
+	values, _ := store.Load(cookie)
+	assert.Equal(t, "1", values["a"])
+	store.Delete(cookie)
+	values, _ = store.Load(cookie)
+	assert.Nil(t, values)
+
+	// 签名有效但已删除的ID不会被重新启用
+	renewed, err := store.Save(cookie, map[string]string{"a": "2"}, time.Hour)
+	assert.Nil(t, err)
+	assert.NotEqual(t, cookie, renewed)
+	values, _ = store.Load(cookie)
+	assert.Nil(t, values)
+
+	// 仍然有效的会话沿用原来的ID
+	again, _ := store.Save(renewed, map[string]string{"a": "3"}, time.Hour)
+	assert.Equal(t, renewed, again)
+
+	cookie, _ = store.Save("", map[string]string{"a": "1"}, -time.Second)
+	values, _ = store.Load(cookie)
+	assert.Nil(t, values)
+	// 已过期的ID同样不会被重新启用
+	renewed, _ = store.Save(cookie, map[string]string{"a": "1"}, time.Hour)
+	assert.NotEqual(t, cookie, renewed)
+
+	// 过期的会话不会在每次Save时清理，间隔memorySweepInterval后才清理
+	store.sessions["expired"] = memorySession{expires: time.Now().Add(-time.Second)}
+	store.Save("", map[string]string{"a": "1"}, time.Hour)
+	assert.Contains(t, store.sessions, "expired")
+	store.lastSweep = time.Now().Add(-memorySweepInterval)
+	store.Save("", map[string]string{"a": "1"}, time.Hour)
+	assert.NotContains(t, store.sessions, "expired")
+}
+
+func TestCookieStore(t *testing.T) {
+	store, err := NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	assert.Nil(t, err)
+	testSessionFlow(t, store)
+
+	cookie, _ := store.Save("", map[string]string{"a": "1"}, -2*time.Second)
+	values, _ := store.Load(cookie)
+	assert.Nil(t, values)
+
+	_, err = NewCookieStore([]byte("short"))
+	assert.Error(t, err)
+}
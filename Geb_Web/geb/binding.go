@@ -0,0 +1,195 @@
+package geb
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 表单与查询参数按字段的form标签取值，没有form标签时使用字段名，
+// 标签为"-"的字段会被忽略；JSON使用encoding/json的json标签
+//
+// 解码成功后会按binding标签校验，规则见validate
+
+// Bind 根据Content-Type选择解码方式：application/json按JSON解码，
+// application/x-www-form-urlencoded和multipart/form-data按表单解码，
+// GET等没有请求体的请求按查询参数解码
+func (c *Context) Bind(obj interface{}) error {
+	if c.Req.Method == http.MethodGet || c.Req.Method == http.MethodHead {
+		return c.BindQuery(obj)
+	}
+	ct, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	switch ct {
+	case "application/json":
+		return c.BindJSON(obj)
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		return c.BindForm(obj)
+	case "":
+		return c.BindQuery(obj)
+	}
+	return NewHTTPError(http.StatusUnsupportedMediaType, "不支持的Content-Type："+ct)
+}
+
+// 请求内容无法解码时返回400，而不是作为内部错误处理
+func bindingError(message string, err error) *HTTPError {
+	return &HTTPError{Code: http.StatusBadRequest, Message: message, Err: err}
+}
+
+// BindJSON 将请求体按JSON解码到obj并校验
+func (c *Context) BindJSON(obj interface{}) error {
+	if c.Req.Body == nil || c.Req.Body == http.NoBody {
+		return NewHTTPError(http.StatusBadRequest, "请求体为空")
+	}
+	if err := json.NewDecoder(c.Req.Body).Decode(obj); err != nil {
+		return bindingError("请求体不是有效的JSON", err)
+	}
+	return validate(obj)
+}
+
+// BindQuery 将URL查询参数解码到obj并校验
+func (c *Context) BindQuery(obj interface{}) error {
+	if err := mapForm(obj, c.Req.URL.Query()); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// BindForm 将表单（包括multipart表单中的普通字段）与查询参数解码到obj并校验
+func (c *Context) BindForm(obj interface{}) error {
+	ct, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	if ct == "multipart/form-data" {
+		if _, err := c.MultipartForm(); err != nil {
+			return err
+		}
+	} else if err := c.Req.ParseForm(); err != nil {
+		return bindingError("表单格式错误", err)
+	}
+	if err := mapForm(obj, c.Req.Form); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+func mapForm(obj interface{}, values url.Values) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("绑定目标必须是指向结构体的指针")
+	}
+	return mapStruct(v.Elem(), values)
+}
+
+func mapStruct(v reflect.Value, values url.Values) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := v.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		name := sf.Tag.Get("form")
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && fv.Kind() == reflect.Struct && name == "" {
+			if err := mapStruct(fv, values); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		vs, ok := values[name]
+		if !ok || len(vs) == 0 {
+			continue
+		}
+		if err := setField(fv, vs); err != nil {
+			return bindingError("字段"+name+"格式错误", err)
+		}
+	}
+	return nil
+}
+
+func setField(fv reflect.Value, vs []string) error {
+	switch fv.Kind() {
+	case reflect.Slice:
+		s := reflect.MakeSlice(fv.Type(), len(vs), len(vs))
+		for i, str := range vs {
+			if err := setValue(s.Index(i), str); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	case reflect.Ptr:
+		p := reflect.New(fv.Type().Elem())
+		if err := setValue(p.Elem(), vs[0]); err != nil {
+			return err
+		}
+		fv.Set(p)
+		return nil
+	}
+	return setValue(fv, vs[0])
+}
+
+func setValue(fv reflect.Value, str string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(str)
+	case reflect.Bool:
+		if str == "" {
+			str = "false"
+		}
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if str == "" {
+			str = "0"
+		}
+		n, err := strconv.ParseInt(str, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if str == "" {
+			str = "0"
+		}
+		n, err := strconv.ParseUint(str, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if str == "" {
+			str = "0"
+		}
+		f, err := strconv.ParseFloat(str, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("不支持的类型%s", fv.Type())
+	}
+	return nil
+}
+
+// 用于校验错误中的字段名：form标签优先，其次是json标签，最后是字段名
+func fieldName(sf reflect.StructField) string {
+	if name := sf.Tag.Get("form"); name != "" && name != "-" {
+		return name
+	}
+	if name := strings.Split(sf.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return sf.Name
+}
@@ -0,0 +1,127 @@
+package geb
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type Page struct {
+	Page int `form:"page" binding:"min=1"`
+	Size int `form:"size" binding:"max=100"`
+}
+
+type userForm struct {
+	Page
+	Name  string   `json:"name" form:"name" binding:"required,min=2,max=8"`
+	Role  string   `json:"role" form:"role" binding:"oneof=admin user"`
+	Code  string   `json:"code" form:"code" binding:"regexp=^[a-z]+$"`
+	Tags  []string `json:"tags" form:"tag"`
+	Admin *bool    `json:"admin" form:"admin"`
+	Skip  string   `form:"-"`
+}
+
+func bindReq(req *http.Request, obj interface{}) error {
+	c := newCtx(New(), httptest.NewRecorder(), req, nil, nil)
+	return c.Bind(obj)
+}
+
+func TestBindQuery(t *testing.T) {
+	var f userForm
+	req := httptest.NewRequest("GET", "/?name=weiwei&role=admin&code=ab&tag=a&tag=b&admin=true&page=2&size=10&Skip=x", nil)
+	assert.Nil(t, bindReq(req, &f))
+	assert.Equal(t, "weiwei", f.Name)
+	assert.Equal(t, []string{"a", "b"}, f.Tags)
+	assert.True(t, *f.Admin)
+	assert.Equal(t, 2, f.Page.Page)
+	assert.Equal(t, "", f.Skip)
+
+	f = userForm{}
+	req = httptest.NewRequest("GET", "/?page=x", nil)
+	assertBadRequest(t, bindReq(req, &f))
+}
+
+// 客户端输入错误返回400
+func assertBadRequest(t *testing.T, err error) {
+	var he *HTTPError
+	if assert.True(t, errors.As(err, &he), "%v", err) {
+		assert.Equal(t, http.StatusBadRequest, he.StatusCode())
+	}
+}
+
+func TestBindForm(t *testing.T) {
+	var f userForm
+	req := httptest.NewRequest("POST", "/", strings.NewReader("name=weiwei&role=user&code=a&page=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	assert.Nil(t, bindReq(req, &f))
+	assert.Equal(t, "user", f.Role)
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("name", "feifei")
+	mw.WriteField("role", "admin")
+	mw.WriteField("code", "x")
+	mw.WriteField("page", "3")
+	mw.Close()
+	f = userForm{}
+	req = httptest.NewRequest("POST", "/", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	assert.Nil(t, bindReq(req, &f))
+	assert.Equal(t, "feifei", f.Name)
+	assert.Equal(t, 3, f.Page.Page)
+}
+
+func TestBindJSON(t *testing.T) {
+	var f userForm
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"w","role":"root","code":"A1","Page":0,"Size":101}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	err := bindReq(req, &f)
+	ve, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	fields := ve.Fields()
+	assert.Equal(t, 5, len(fields))
+	assert.Contains(t, fields, "name")
+	assert.Contains(t, fields, "role")
+	assert.Contains(t, fields, "code")
+	assert.Contains(t, fields, "page")
+	assert.Contains(t, fields, "size")
+
+	req = httptest.NewRequest("PUT", "/", strings.NewReader(`<a/>`))
+	req.Header.Set("Content-Type", "text/xml")
+	var he *HTTPError
+	assert.True(t, errors.As(bindReq(req, &f), &he))
+	assert.Equal(t, http.StatusUnsupportedMediaType, he.Code)
+
+	for _, body := range []string{`{"name":`, `{"page":"x"}`, `[1]`, ``} {
+		req = httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		assertBadRequest(t, bindReq(req, &f))
+	}
+}
+
+func TestBindErrorResponse(t *testing.T) {
+	e := New()
+	e.Any("/n", func(c *Context) {
+		var f struct {
+			N int `json:"n" form:"n"`
+		}
+		c.Error(c.Bind(&f))
+	})
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/n?n=abc", nil),
+		httptest.NewRequest("POST", "/n", strings.NewReader(`{"n":"x"}`)),
+	} {
+		if req.Method == "POST" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"detail":"`)
+	}
+}
@@ -0,0 +1,236 @@
+// Package compress 根据Accept-Encoding使用gzip或deflate压缩响应的中间件
+package compress
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"geb"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Config 压缩配置
+type Config struct {
+	// Level 压缩级别，取值与compress/flate相同，默认为flate.DefaultCompression
+	Level int
+	// MinSize 响应体小于该字节数时不压缩，默认1024
+	MinSize int
+	// ContentTypes 需要压缩的Content-Type前缀，默认为常见的文本类型
+	ContentTypes []string
+	// ExcludedPaths 不压缩的路径前缀
+	ExcludedPaths []string
+}
+
+var defaultContentTypes = []string{
+	"text/html", "text/css", "text/plain", "text/xml", "text/javascript", "text/csv",
+	"application/json", "application/javascript", "application/xml", "application/yaml",
+	"application/problem+json", "image/svg+xml",
+}
+
+const defaultMinSize = 1024
+
+func Default() geb.HandlerFunc {
+	return New(Config{Level: flate.DefaultCompression})
+}
+
+// New 返回压缩中间件，响应体先缓存到MinSize字节再决定是否压缩；
+// 已经设置了Content-Encoding的响应、HEAD请求与204/304响应不会被压缩
+func New(conf Config) geb.HandlerFunc {
+	if conf.Level == 0 {
+		conf.Level = flate.DefaultCompression
+	}
+	if conf.MinSize <= 0 {
+		conf.MinSize = defaultMinSize
+	}
+	if len(conf.ContentTypes) == 0 {
+		conf.ContentTypes = defaultContentTypes
+	}
+	return func(c *geb.Context) {
+		for _, p := range conf.ExcludedPaths {
+			if strings.HasPrefix(c.Req.URL.Path, p) {
+				return
+			}
+		}
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiate(c.Req.Header.Get("Accept-Encoding"))
+		if encoding == "" || c.Req.Method == http.MethodHead {
+			return
+		}
+		w := &writer{ResponseWriter: c.Writer, conf: &conf, encoding: encoding}
+		c.Writer = w
+		defer func() {
+			w.close()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// 返回gzip、deflate或空字符串，q值相同时优先gzip
+func negotiate(accept string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				v, err := strconv.ParseFloat(f[2:], 64)
+				if err != nil {
+					v = 0
+				}
+				q = v
+			}
+		}
+		if name == "*" {
+			name = "gzip"
+		}
+		if (name == "gzip" || name == "deflate") && q > 0 && (q > bestQ || (q == bestQ && name == "gzip")) {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+type writer struct {
+	geb.ResponseWriter
+	conf     *Config
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	size    int
+	comp    io.WriteCloser
+}
+
+func (w *writer) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *writer) Write(data []byte) (int, error) {
+	w.size += len(data)
+	if w.decided {
+		return w.write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.conf.MinSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *writer) write(data []byte) (int, error) {
+	if w.comp != nil {
+		return w.comp.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// 根据已缓存的数据决定是否压缩，写出状态码与缓存的数据
+func (w *writer) decide() error {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.ResponseWriter.Header()
+	if w.shouldCompress() {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		if w.encoding == "gzip" {
+			w.comp, _ = gzip.NewWriterLevel(w.ResponseWriter, w.conf.Level)
+		} else {
+			w.comp, _ = flate.NewWriter(w.ResponseWriter, w.conf.Level)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.write(buf)
+	return err
+}
+
+func (w *writer) shouldCompress() bool {
+	if len(w.buf) < w.conf.MinSize || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	// 范围响应的Content-Range指的是原始内容的字节位置，压缩后客户端无法拼接
+	if w.status == http.StatusPartialContent {
+		return false
+	}
+	h := w.ResponseWriter.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(w.buf)
+		h.Set("Content-Type", ct)
+	}
+	for _, t := range w.conf.ContentTypes {
+		if strings.HasPrefix(ct, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Flush 此时尚未决定是否压缩的，按已缓存的数据决定
+func (w *writer) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if f, ok := w.comp.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *writer) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			// 处理函数没有写出任何内容，由后续的错误处理等逻辑决定响应
+			return
+		}
+		w.decide()
+	}
+	if w.comp != nil {
+		w.comp.Close()
+	}
+}
+
+func (w *writer) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *writer) Size() int {
+	return w.size
+}
+
+func (w *writer) Written() bool {
+	return w.status != 0 || w.ResponseWriter.Written()
+}
+
+func (w *writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return w.ResponseWriter.Hijack()
+}
+
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
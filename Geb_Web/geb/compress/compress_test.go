@@ -0,0 +1,110 @@
+package compress
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"geb"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var long = strings.Repeat("hello geb ", 200)
+
+func newEngine() *geb.Engine {
+	e := geb.New()
+	e.Use(New(Config{ExcludedPaths: []string{"/raw"}}))
+	e.GET("/long", func(c *geb.Context) {
+		c.Text(http.StatusOK, long)
+	})
+	e.GET("/short", func(c *geb.Context) {
+		c.Text(http.StatusOK, "hi")
+	})
+	e.GET("/png", func(c *geb.Context) {
+		c.SetContentType("image/png")
+		c.Data(http.StatusOK, []byte(long))
+	})
+	e.GET("/raw", func(c *geb.Context) {
+		c.Text(http.StatusOK, long)
+	})
+	return e
+}
+
+func get(e *geb.Engine, path, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set("Accept-Encoding", accept)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestNegotiate(t *testing.T) {
+	assert.Equal(t, "gzip", negotiate("gzip, deflate"))
+	assert.Equal(t, "deflate", negotiate("deflate, gzip;q=0.5"))
+	assert.Equal(t, "gzip", negotiate("*"))
+	assert.Equal(t, "", negotiate("br"))
+	assert.Equal(t, "", negotiate("gzip;q=0"))
+}
+
+func TestGzip(t *testing.T) {
+	e := newEngine()
+	w := get(e, "/long", "gzip, deflate")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	r, err := gzip.NewReader(w.Body)
+	assert.Nil(t, err)
+	b, _ := io.ReadAll(r)
+	assert.Equal(t, long, string(b))
+}
+
+func TestDeflate(t *testing.T) {
+	e := newEngine()
+	w := get(e, "/long", "deflate")
+	assert.Equal(t, "deflate", w.Header().Get("Content-Encoding"))
+	b, _ := io.ReadAll(flate.NewReader(w.Body))
+	assert.Equal(t, long, string(b))
+}
+
+func TestNotCompressed(t *testing.T) {
+	e := newEngine()
+	for _, c := range []struct{ path, accept string }{
+		{"/short", "gzip"},
+		{"/png", "gzip"},
+		{"/raw", "gzip"},
+		{"/long", ""},
+	} {
+		w := get(e, c.path, c.accept)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Encoding"), c.path)
+		assert.NotEmpty(t, w.Body.String())
+	}
+	w := get(e, "/none", "gzip")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRangeNotCompressed(t *testing.T) {
+	e := newEngine()
+	e.GET("/file", func(c *geb.Context) {
+		http.ServeContent(c.Writer, c.Req, "long.txt", time.Time{}, strings.NewReader(long))
+	})
+	req := httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=100-1899")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "bytes 100-1899/2000", w.Header().Get("Content-Range"))
+	assert.Equal(t, long[100:1900], w.Body.String())
+
+	// 没有Range时照常压缩
+	req.Header.Del("Range")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+}
@@ -1,22 +1,34 @@
 package geb
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 )
 
-// Context 上下文
+// Context 上下文，实现了context.Context，Deadline、Done和Err委托给Req.Context()，
+// 因此可以直接传给需要context.Context的函数
 type Context struct {
 	// 原始数据
-	Writer http.ResponseWriter
+	Writer ResponseWriter
 	Req    *http.Request
 
-	engine *Engine
+	engine    *Engine
+	params    Params
+	patten    string
+	requestID string
+
+	errors []error
+
+	// 中间件向后续处理函数传递数据
+	mu   sync.RWMutex
+	keys map[interface{}]interface{}
 
 	handlers []HandlerFunc
 	i        int
-	stoped   bool
+	aborted  bool
 }
 
 // 等价于c.Writer.Write([]byte)
@@ -34,6 +46,31 @@ func (c *Context) SetStatus(status int) {
 	c.Writer.WriteHeader(status)
 }
 
+// Param 返回名为name的路径参数，参数不存在时返回空字符串
+func (c *Context) Param(name string) string {
+	return c.params.ByName(name)
+}
+
+// Params 返回本次匹配捕获的全部路径参数
+func (c *Context) Params() Params {
+	return c.params
+}
+
+// Patten 返回匹配到的路由模式，如"/users/:id"，没有匹配到路由时返回空字符串
+func (c *Context) Patten() string {
+	return c.patten
+}
+
+// RequestID 返回RequestID中间件设置的请求ID，未使用该中间件时返回空字符串
+func (c *Context) RequestID() string {
+	return c.requestID
+}
+
+// ClientIP 返回请求的对端IP，不会信任X-Forwarded-For等可伪造的请求头
+func (c *Context) ClientIP() string {
+	return clientIP(c.Req)
+}
+
 func (c *Context) SetContentType(t string) {
 	c.SetHeader("Content-Type", t)
 }
@@ -45,10 +82,7 @@ func (c *Context) Text(status int, str string) (int, error) {
 }
 
 func (c *Context) JSON(status int, obj interface{}) error {
-	c.SetContentType("application/json")
-	c.SetStatus(status)
-	encoder := json.NewEncoder(c.Writer)
-	return encoder.Encode(obj)
+	return c.Render(status, JSONRender{obj})
 }
 
 func (c *Context) Data(status int, data []byte) (int, error) {
@@ -56,21 +90,36 @@ func (c *Context) Data(status int, data []byte) (int, error) {
 	return c.Write(data)
 }
 
-// todo
-func (c *Context) HTML(status int, source string, data interface{}) error {
-	return fmt.Errorf("暂时还未实现，%s", "嘿嘿")
-	//c.SetContentType("text/html")
-	//c.SetStatus(status)
-	//return c.engine.htmlTemplate.ExecuteTemplate(c.Writer, source, data)
+// Fail 等价于AbortWithStatus(code)
+func (c *Context) Fail(code int) {
+	c.AbortWithStatus(code)
 }
 
-func (c *Context) Fail(code int) {
+// Abort 当前处理函数返回后不再执行后续的处理函数，已经执行到一半的中间件
+// （在Next之后还有代码的）仍会继续执行完毕
+func (c *Context) Abort() {
+	c.aborted = true
+}
+
+// AbortWithStatus 写出状态码并终止处理链
+func (c *Context) AbortWithStatus(code int) {
 	c.Data(code, nil)
-	c.i = len(c.handlers)
+	c.Abort()
+}
+
+// AbortWithStatusJSON 以JSON写出obj并终止处理链
+func (c *Context) AbortWithStatusJSON(code int, obj interface{}) error {
+	c.Abort()
+	return c.JSON(code, obj)
+}
+
+// IsAborted 返回处理链是否已被终止
+func (c *Context) IsAborted() bool {
+	return c.aborted
 }
 
 func (c *Context) run() {
-	for c.i < len(c.handlers) {
+	for c.i < len(c.handlers) && !c.aborted {
 		c.handlers[c.i](c)
 		c.i++
 	}
@@ -81,6 +130,107 @@ func (c *Context) Next() {
 	c.run()
 }
 
-func newCtx(w http.ResponseWriter, req *http.Request, handlers []HandlerFunc) *Context {
-	return &Context{Writer: w, Req: req, handlers: handlers}
+// Set 保存一个键值对，供后续的处理函数通过Get获取
+func (c *Context) Set(key string, value interface{}) {
+	c.set(key, value)
+}
+
+// Get 返回Set保存的值
+func (c *Context) Get(key string) (value interface{}, ok bool) {
+	return c.get(key)
+}
+
+// MustGet 返回Set保存的值，不存在时panic
+func (c *Context) MustGet(key string) interface{} {
+	if v, ok := c.get(key); ok {
+		return v
+	}
+	panic(fmt.Sprintf("key %q does not exist", key))
+}
+
+func (c *Context) set(key, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		c.keys = make(map[interface{}]interface{})
+	}
+	c.keys[key] = value
+}
+
+func (c *Context) get(key interface{}) (value interface{}, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, ok = c.keys[key]
+	return
+}
+
+// Key 带类型的键，不同的Key即使名字相同也互不影响，例如：
+//
+// var UserKey = geb.NewKey[*User]("user")
+//
+// UserKey.Set(c, u)
+//
+// u, ok := UserKey.Get(c)
+type Key[T any] struct {
+	name string
+}
+
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+func (k *Key[T]) String() string {
+	return k.name
+}
+
+func (k *Key[T]) Set(c *Context, value T) {
+	c.set(k, value)
+}
+
+func (k *Key[T]) Get(c *Context) (value T, ok bool) {
+	v, ok := c.get(k)
+	if !ok {
+		return value, false
+	}
+	return v.(T), true
+}
+
+// MustGet 与Get相同，不存在时panic
+func (k *Key[T]) MustGet(c *Context) T {
+	v, ok := k.Get(c)
+	if !ok {
+		panic(fmt.Sprintf("key %q does not exist", k.name))
+	}
+	return v
+}
+
+func (c *Context) requestContext() context.Context {
+	if c.Req == nil {
+		return context.Background()
+	}
+	return c.Req.Context()
+}
+
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	return c.requestContext().Deadline()
+}
+
+func (c *Context) Done() <-chan struct{} {
+	return c.requestContext().Done()
+}
+
+func (c *Context) Err() error {
+	return c.requestContext().Err()
+}
+
+// Value 先查找Set或Key保存的值，找不到时查找请求的context
+func (c *Context) Value(key interface{}) interface{} {
+	if v, ok := c.get(key); ok {
+		return v
+	}
+	return c.requestContext().Value(key)
+}
+
+func newCtx(e *Engine, w http.ResponseWriter, req *http.Request, handlers []HandlerFunc, params Params) *Context {
+	return &Context{Writer: newResponseWriter(w), Req: req, engine: e, handlers: handlers, params: params}
 }
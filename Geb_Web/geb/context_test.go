@@ -1,12 +1,14 @@
 package geb
 
 import (
+	"context"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestContext1(t *testing.T) {
@@ -92,3 +94,78 @@ func TestContext2(t *testing.T) {
 	assert.Equal(t, 500, w.Code)
 	assert.Equal(t, "az", out.String())
 }
+
+func TestContextParam(t *testing.T) {
+	e := New()
+	e.GET("/users/:id/files/*path", func(c *Context) {
+		c.Text(http.StatusOK, c.Param("id")+":"+c.Param("path")+" "+c.Patten())
+	})
+	req := httptest.NewRequest("GET", "/users/7/files/a/b.txt", nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "7:a/b.txt /users/:id/files/*path", w.Body.String())
+}
+
+type ctxKey string
+
+func TestContextStoreAndAbort(t *testing.T) {
+	type user struct{ Name string }
+	userKey := NewKey[*user]("user")
+	otherKey := NewKey[*user]("user")
+	e := New()
+	var out strings.Builder
+	e.Use(func(c *Context) {
+		if c.Req.Header.Get("Token") == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": "no token"})
+			out.WriteString("abort")
+			return
+		}
+		userKey.Set(c, &user{Name: "weiwei"})
+		c.Set("role", "admin")
+		c.Next()
+		out.WriteString(fmt.Sprint(c.IsAborted()))
+	})
+	e.GET("/me", func(c *Context) {
+		u := userKey.MustGet(c)
+		_, ok := otherKey.Get(c)
+		assert.False(t, ok)
+		assert.Equal(t, "admin", c.MustGet("role"))
+		_, ok = c.Get("none")
+		assert.False(t, ok)
+		assert.Panics(t, func() { c.MustGet("none") })
+		assert.Panics(t, func() { otherKey.MustGet(c) })
+		assert.Equal(t, "v", c.Value(ctxKey("k")))
+		assert.Equal(t, "admin", c.Value("role"))
+		c.Text(http.StatusOK, u.Name)
+	})
+
+	req := httptest.NewRequest("GET", "/me", nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `{"error":"no token"}`+"\n", w.Body.String())
+	assert.Equal(t, "abort", out.String())
+
+	out.Reset()
+	req = httptest.NewRequest("GET", "/me", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKey("k"), "v"))
+	req.Header.Set("Token", "x")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "weiwei", w.Body.String())
+	assert.Equal(t, "false", out.String())
+}
+
+func TestContextCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	c := newCtx(New(), httptest.NewRecorder(), req, nil, nil)
+	var _ context.Context = c
+	_, ok := c.Deadline()
+	assert.True(t, ok)
+	assert.Nil(t, c.Err())
+	cancel()
+	<-c.Done()
+	assert.Equal(t, context.Canceled, c.Err())
+}
@@ -0,0 +1,123 @@
+// Package cors 跨域资源共享中间件
+package cors
+
+import (
+	"geb"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config CORS配置
+type Config struct {
+	// AllowOrigins 允许的源，"*"表示全部，也支持"https://*.example.com"形式的子域名通配
+	AllowOrigins []string
+	// AllowOriginFunc 自定义判断，设置后优先于AllowOrigins
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods 预检请求允许的方法，默认为GET、POST、PUT、PATCH、DELETE、HEAD
+	AllowMethods []string
+	// AllowHeaders 预检请求允许的请求头，为空时回显请求中的Access-Control-Request-Headers
+	AllowHeaders []string
+	// ExposeHeaders 允许浏览器读取的响应头
+	ExposeHeaders []string
+	// AllowCredentials 是否允许携带cookie等凭证，此时不会返回"*"而是回显请求的源
+	AllowCredentials bool
+	// MaxAge 预检结果的缓存时间
+	MaxAge time.Duration
+}
+
+// Default 允许任意源，不允许携带凭证
+func Default() geb.HandlerFunc {
+	return New(Config{AllowOrigins: []string{"*"}})
+}
+
+// New 返回CORS中间件，预检请求（带有Access-Control-Request-Method的OPTIONS请求）
+// 会直接以204响应并终止处理链，不被允许的源不会得到任何CORS响应头
+func New(conf Config) geb.HandlerFunc {
+	methods := conf.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead}
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(conf.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(conf.ExposeHeaders, ", ")
+	maxAge := ""
+	if conf.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(conf.MaxAge / time.Second))
+	}
+	allowAll := false
+	for _, o := range conf.AllowOrigins {
+		if o == "*" {
+			allowAll = true
+		}
+	}
+	allowed := func(origin string) bool {
+		if conf.AllowOriginFunc != nil {
+			return conf.AllowOriginFunc(origin)
+		}
+		if allowAll {
+			return true
+		}
+		for _, o := range conf.AllowOrigins {
+			if matchOrigin(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(c *geb.Context) {
+		origin := c.Req.Header.Get("Origin")
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		if origin == "" {
+			return
+		}
+		preflight := c.Req.Method == http.MethodOptions && c.Req.Header.Get("Access-Control-Request-Method") != ""
+		if !allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+			}
+			return
+		}
+		if allowAll && !conf.AllowCredentials && conf.AllowOriginFunc == nil {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if conf.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			if exposeHeaders != "" {
+				h.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			return
+		}
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+		} else if reqHeaders := c.Req.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", reqHeaders)
+		}
+		if maxAge != "" {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// pattern中可以包含一个"*"，例如https://*.example.com
+func matchOrigin(pattern, origin string) bool {
+	i := strings.Index(pattern, "*")
+	if i < 0 {
+		return strings.EqualFold(pattern, origin)
+	}
+	prefix, suffix := pattern[:i], pattern[i+1:]
+	return len(origin) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+		strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix))
+}
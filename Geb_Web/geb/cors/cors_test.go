@@ -0,0 +1,86 @@
+package cors
+
+import (
+	"geb"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newEngine(conf Config) *geb.Engine {
+	e := geb.New()
+	e.Use(New(conf))
+	e.GET("/a", func(c *geb.Context) {
+		c.Text(http.StatusOK, "a")
+	})
+	return e
+}
+
+func do(e *geb.Engine, method, origin string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/a", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestDefault(t *testing.T) {
+	e := geb.New()
+	e.Use(Default())
+	e.GET("/a", func(c *geb.Context) {})
+	w := do(e, "GET", "http://x.com", nil)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	w = do(e, "GET", "", nil)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestAllowedOrigins(t *testing.T) {
+	e := newEngine(Config{
+		AllowOrigins:     []string{"https://app.com", "https://*.example.com"},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Total"},
+		MaxAge:           time.Hour,
+	})
+	w := do(e, "GET", "https://app.com", nil)
+	assert.Equal(t, "a", w.Body.String())
+	assert.Equal(t, "https://app.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "X-Total", w.Header().Get("Access-Control-Expose-Headers"))
+
+	w = do(e, "GET", "https://api.example.com", nil)
+	assert.Equal(t, "https://api.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = do(e, "GET", "https://evil.com", nil)
+	assert.Equal(t, "a", w.Body.String())
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = do(e, "OPTIONS", "https://app.com", map[string]string{
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "X-Token",
+	})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "GET, POST, PUT, PATCH, DELETE, HEAD", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "X-Token", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "3600", w.Header().Get("Access-Control-Max-Age"))
+
+	w = do(e, "OPTIONS", "https://evil.com", map[string]string{"Access-Control-Request-Method": "PUT"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestPreflightUnknownPath(t *testing.T) {
+	e := newEngine(Config{AllowOriginFunc: func(origin string) bool { return origin == "https://app.com" }})
+	req := httptest.NewRequest("OPTIONS", "/none", nil)
+	req.Header.Set("Origin", "https://app.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.com", w.Header().Get("Access-Control-Allow-Origin"))
+}
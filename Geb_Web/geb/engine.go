@@ -1,29 +1,81 @@
 package geb
 
 import (
+	"context"
 	"fmt"
 	"html/template"
-	"log"
+	"net"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 )
 
 type Engine struct {
-	htmlTemplate *template.Template
-	router       Router
+	*RouterGroup
 
-	started bool
-	lock    sync.Mutex
+	html    htmlRender
+	renders *renderRegistry
+	router  Router
+
+	noRoute      []HandlerFunc
+	noMethod     []HandlerFunc
+	errorHandler ErrorHandler
+
+	state    State
+	server   *http.Server
+	timeouts Timeouts
+	uploads  UploadConfig
+	// 正在执行的请求，Shutdown会等待它们全部结束
+	active sync.WaitGroup
+	lock   sync.Mutex
+}
+
+// State Engine的生命周期状态，只能按Idle -> Running -> ShuttingDown -> Closed的顺序变化
+type State int
+
+const (
+	// StateIdle 尚未启动，可以修改配置
+	StateIdle State = iota
+	// StateRunning 正在接收请求
+	StateRunning
+	// StateShuttingDown 已停止接收新请求，等待正在执行的请求结束
+	StateShuttingDown
+	// StateClosed 已关闭，不能再次启动
+	StateClosed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateRunning:
+		return "running"
+	case StateShuttingDown:
+		return "shutting down"
+	case StateClosed:
+		return "closed"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Timeouts 服务器超时设置，零值表示不超时，含义与http.Server中的同名字段相同
+type Timeouts struct {
+	Read       time.Duration
+	ReadHeader time.Duration
+	Write      time.Duration
+	Idle       time.Duration
 }
 
 func New() *Engine {
-	// templ := template.Must(template.New("").ParseGlob("templates/*"))
-	return &Engine{htmlTemplate: nil, router: &defaultRouter{}}
+	e := &Engine{router: &defaultRouter{}, renders: newRenderRegistry()}
+	e.RouterGroup = &RouterGroup{engine: e}
+	return e
 }
 
 func hasRun(e *Engine) error {
 	e.lock.Lock()
-	if e.started {
+	if e.state != StateIdle {
 		e.lock.Unlock()
 		return fmt.Errorf("engine has started")
 	}
@@ -31,47 +83,204 @@ func hasRun(e *Engine) error {
 	return nil
 }
 
+// State 返回Engine当前的生命周期状态
+func (e *Engine) State() State {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.state
+}
+
 func (e *Engine) Template(templ *template.Template) error {
 	if err := hasRun(e); err != nil {
 		return err
 	}
-	e.htmlTemplate = templ
+	e.html.setTemplate(templ)
 	return nil
 }
 
-func (e *Engine) Run(addr string) (err error) {
+// SetTimeouts 设置服务器的读写与空闲超时，必须在启动前调用
+func (e *Engine) SetTimeouts(t Timeouts) error {
 	e.lock.Lock()
-	if e.started {
-		e.lock.Unlock()
+	defer e.lock.Unlock()
+	if e.state != StateIdle {
 		return fmt.Errorf("engine has started")
-	} else {
-		e.started = true
-		e.lock.Unlock()
 	}
-	return http.ListenAndServe(addr, e)
+	e.timeouts = t
+	return nil
+}
+
+func (e *Engine) start(addr string) (*http.Server, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.state != StateIdle {
+		return nil, fmt.Errorf("engine has started")
+	}
+	e.state = StateRunning
+	e.server = &http.Server{
+		Addr:              addr,
+		Handler:           e,
+		ReadTimeout:       e.timeouts.Read,
+		ReadHeaderTimeout: e.timeouts.ReadHeader,
+		WriteTimeout:      e.timeouts.Write,
+		IdleTimeout:       e.timeouts.Idle,
+	}
+	return e.server, nil
+}
+
+// 服务因Shutdown结束时返回nil，因其它错误结束时Engine进入Closed状态
+func (e *Engine) served(err error) error {
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	e.lock.Lock()
+	e.state = StateClosed
+	e.lock.Unlock()
+	return err
+}
+
+// Run 在addr上启动HTTP服务，阻塞直到服务结束，调用Shutdown后返回nil
+func (e *Engine) Run(addr string) (err error) {
+	srv, err := e.start(addr)
+	if err != nil {
+		return err
+	}
+	return e.served(srv.ListenAndServe())
 }
 
-func (e *Engine) GET(patten string, handler HandlerFunc) error {
-	return e.router.AddHandler("GET", patten, handler)
+// RunTLS 在addr上启动HTTPS服务，客户端支持时自动使用HTTP/2
+func (e *Engine) RunTLS(addr, certFile, keyFile string) error {
+	srv, err := e.start(addr)
+	if err != nil {
+		return err
+	}
+	return e.served(srv.ListenAndServeTLS(certFile, keyFile))
+}
+
+// RunListener 在已有的listener上启动HTTP服务
+func (e *Engine) RunListener(l net.Listener) error {
+	srv, err := e.start(l.Addr().String())
+	if err != nil {
+		return err
+	}
+	return e.served(srv.Serve(l))
 }
-func (e *Engine) POST(patten string, handler HandlerFunc) error {
-	return e.router.AddHandler("POST", patten, handler)
+
+// Shutdown 停止接收新的连接与请求，等待正在执行的处理函数全部结束后返回，
+// ctx到期时不再等待并返回ctx.Err()；对未启动的Engine调用会直接将其关闭
+func (e *Engine) Shutdown(ctx context.Context) error {
+	e.lock.Lock()
+	switch e.state {
+	case StateIdle:
+		e.state = StateClosed
+		e.lock.Unlock()
+		return nil
+	case StateRunning:
+	default:
+		e.lock.Unlock()
+		return fmt.Errorf("engine is %s", e.state)
+	}
+	e.state = StateShuttingDown
+	srv := e.server
+	e.lock.Unlock()
+
+	err := srv.Shutdown(ctx)
+	if err == nil {
+		// 被劫持的连接（例如WebSocket）不受http.Server.Shutdown管理，在这里等待
+		done := make(chan struct{})
+		go func() {
+			e.active.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	e.lock.Lock()
+	e.state = StateClosed
+	e.lock.Unlock()
+	return err
 }
 
 func (e *Engine) AddMiddlewire(path string, handler ...HandlerFunc) error {
 	return e.router.AddMiddlewire(path, handler...)
 }
 
+// Routes 按注册顺序返回全部路由，可用于启动时打印路由表
+func (e *Engine) Routes() []RouteInfo {
+	return e.router.Routes()
+}
+
+// NoRoute 设置没有匹配到路由时的处理函数，默认返回404
+func (e *Engine) NoRoute(handlers ...HandlerFunc) {
+	e.noRoute = handlers
+}
+
+// NoMethod 设置路径存在但方法不匹配时的处理函数，默认返回405，
+// 处理函数执行前响应头中已设置Allow
+func (e *Engine) NoMethod(handlers ...HandlerFunc) {
+	e.noMethod = handlers
+}
+
+// SetErrorHandler 设置错误处理函数，处理链结束后如果Context中有通过Error记录的错误
+// 且响应尚未写出，则调用h，默认输出problem details格式的JSON
+func (e *Engine) SetErrorHandler(h ErrorHandler) {
+	e.errorHandler = h
+}
+
 func (e *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	handler := e.router.Handlers(req.Method, req.URL.Path)
-	ctx := newCtx(w, req, handler)
+	e.active.Add(1)
+	defer e.active.Done()
+	handler, params, patten := e.router.Match(req.Method, req.URL.Path)
+	// 未注册HEAD时使用GET的处理函数，响应体会被net/http丢弃
+	if len(handler) == 0 && req.Method == http.MethodHead {
+		handler, params, patten = e.router.Match(http.MethodGet, req.URL.Path)
+	}
+	ctx := newCtx(e, w, req, handler, params)
+	ctx.patten = patten
 	if len(handler) == 0 {
-		log.Printf("路径错误：%s", req.Method+"-"+req.URL.Path)
-		ctx.Fail(500)
-		if _, err := ctx.Data(http.StatusNotFound, nil); err != nil {
-			log.Printf(err.Error())
+		// 未匹配的请求同样经过沿途的中间件
+		ctx.handlers = e.router.Middlewires(req.URL.Path)
+		if allowed := allowedMethods(e.router, req.URL.Path); len(allowed) > 0 {
+			ctx.SetHeader("Allow", strings.Join(allowed, ", "))
+			if req.Method == http.MethodOptions {
+				ctx.handlers = append(ctx.handlers, func(c *Context) {
+					c.Data(http.StatusNoContent, nil)
+				})
+			} else if len(e.noMethod) > 0 {
+				ctx.handlers = append(ctx.handlers, e.noMethod...)
+			} else {
+				ctx.handlers = append(ctx.handlers, func(c *Context) {
+					c.Error(NewHTTPError(http.StatusMethodNotAllowed, ""))
+				})
+			}
+		} else if len(e.noRoute) > 0 {
+			ctx.handlers = append(ctx.handlers, e.noRoute...)
+		} else {
+			ctx.handlers = append(ctx.handlers, func(c *Context) {
+				c.Error(NewHTTPError(http.StatusNotFound, ""))
+			})
+		}
+	}
+	e.handle(ctx)
+}
+
+// HandleContext 不经过路由，直接用handlers处理请求，结束后与ServeHTTP一样交给ErrorHandler；
+// 返回的Context可以用来检查处理函数保存的值，主要用于单独测试处理函数与中间件
+func (e *Engine) HandleContext(w http.ResponseWriter, req *http.Request, params Params, handlers ...HandlerFunc) *Context {
+	ctx := newCtx(e, w, req, handlers, params)
+	e.handle(ctx)
+	return ctx
+}
+
+func (e *Engine) handle(ctx *Context) {
+	ctx.run()
+	if len(ctx.errors) > 0 && !ctx.Writer.Written() {
+		h := e.errorHandler
+		if h == nil {
+			h = ProblemDetailsHandler
 		}
-	} else {
-		ctx.run()
+		h(ctx, ctx.errors)
 	}
 }
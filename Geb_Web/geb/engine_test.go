@@ -1 +1,193 @@
 package geb
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEngineMethods(t *testing.T) {
+	e := New()
+	for _, m := range []string{"PUT", "DELETE", "PATCH"} {
+		method := m
+		assert.Nil(t, e.Handle(method, "/items/:id", func(c *Context) {
+			c.Text(http.StatusOK, method+" "+c.Param("id"))
+		}))
+	}
+	assert.Error(t, e.Handle("FOO", "/items", func(c *Context) {}))
+	for _, m := range []string{"PUT", "DELETE", "PATCH"} {
+		req := httptest.NewRequest(m, "/items/3", nil)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, m+" 3", w.Body.String())
+	}
+}
+
+func TestEngineRoutes(t *testing.T) {
+	e := New()
+	api := e.Group("/api", func(c *Context) {})
+	assert.Nil(t, api.GET("/users/:id", routeHandler))
+	assert.Error(t, api.GET("/users/:id", routeHandler))
+	assert.Error(t, api.GET("/users/:name", routeHandler))
+	assert.Equal(t, []RouteInfo{
+		{Method: "GET", Patten: "/api/users/:id", Handler: "geb.routeHandler", Middlewires: 1},
+	}, e.Routes())
+}
+
+func TestEngineHeadOptionsAndNotAllowed(t *testing.T) {
+	e := New()
+	e.GET("/a", func(c *Context) {
+		c.SetHeader("X-From", "get")
+		c.Text(http.StatusOK, "a")
+	})
+	e.POST("/a", func(c *Context) {})
+
+	// HEAD回退到GET
+	req := httptest.NewRequest("HEAD", "/a", nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "get", w.Header().Get("X-From"))
+
+	req = httptest.NewRequest("OPTIONS", "/a", nil)
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "GET, POST, HEAD, OPTIONS", w.Header().Get("Allow"))
+
+	req = httptest.NewRequest("DELETE", "/a", nil)
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, POST, HEAD, OPTIONS", w.Header().Get("Allow"))
+
+	req = httptest.NewRequest("DELETE", "/b", nil)
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.NotEqual(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Empty(t, w.Header().Get("Allow"))
+}
+
+func TestEngineShutdown(t *testing.T) {
+	e := New()
+	started := make(chan struct{})
+	e.GET("/slow", func(c *Context) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		c.Text(http.StatusOK, "done")
+	})
+	assert.Nil(t, e.SetTimeouts(Timeouts{Read: time.Second, Write: time.Second, Idle: time.Second}))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	runErr := make(chan error, 1)
+	go func() { runErr <- e.RunListener(l) }()
+
+	body := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + l.Addr().String() + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		body <- string(b)
+	}()
+	<-started
+	assert.Equal(t, StateRunning, e.State())
+	assert.Error(t, e.Run(":0"))
+	assert.Error(t, e.SetTimeouts(Timeouts{}))
+
+	assert.Nil(t, e.Shutdown(context.Background()))
+	assert.Equal(t, StateClosed, e.State())
+	assert.Equal(t, "done", <-body)
+	assert.Nil(t, <-runErr)
+	assert.Error(t, e.Shutdown(context.Background()))
+}
+
+func TestEngineShutdownIdle(t *testing.T) {
+	e := New()
+	assert.Equal(t, StateIdle, e.State())
+	assert.Nil(t, e.Shutdown(context.Background()))
+	assert.Equal(t, StateClosed, e.State())
+	assert.Error(t, e.Run(":0"))
+}
+
+func TestEngineRunTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	e := New()
+	e.GET("/proto", func(c *Context) {
+		c.Text(http.StatusOK, c.Req.Proto)
+	})
+	go e.RunTLS(addr, certFile, keyFile)
+	defer e.Shutdown(context.Background())
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	var res *http.Response
+	for i := 0; i < 50; i++ {
+		if res, err = client.Get("https://" + addr + "/proto"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	assert.Equal(t, "HTTP/2.0", string(b))
+}
+
+func writeTestCert(t *testing.T, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templ := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, templ, templ, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,90 @@
+package geb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrorHandler 将处理过程中通过Context.Error记录的错误转换为响应
+type ErrorHandler func(c *Context, errs []error)
+
+// HTTPError 携带状态码的错误，Message会作为响应中的detail
+type HTTPError struct {
+	Code    int
+	Message string
+	Err     error
+}
+
+// NewHTTPError 创建一个HTTPError，message为空时使用状态码对应的描述
+func NewHTTPError(code int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(code)
+	}
+	return &HTTPError{Code: code, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+func (e *HTTPError) StatusCode() int {
+	return e.Code
+}
+
+// Error 记录一个错误，处理链结束后交给Engine的ErrorHandler处理，返回err本身
+func (c *Context) Error(err error) error {
+	if err != nil {
+		c.errors = append(c.errors, err)
+	}
+	return err
+}
+
+// Errors 返回已记录的全部错误
+func (c *Context) Errors() []error {
+	return c.errors
+}
+
+// ProblemDetails RFC 7807格式的错误响应
+type ProblemDetails struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// ProblemDetailsHandler 默认的ErrorHandler，根据第一个错误生成响应：
+//
+// 实现了StatusCode() int的错误（例如HTTPError）使用其状态码与信息；
+// ValidationErrors返回400，并在errors中列出每个字段的错误；
+// 其它错误返回500，为避免泄露内部信息，不输出错误内容
+func ProblemDetailsHandler(c *Context, errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+	p := ProblemDetails{Type: "about:blank", Status: http.StatusInternalServerError, Instance: c.Req.URL.Path}
+	err := errs[0]
+	var coded interface{ StatusCode() int }
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		p.Status = http.StatusBadRequest
+		p.Detail = ve.Error()
+		p.Errors = ve.Fields()
+	} else if errors.As(err, &coded) {
+		p.Status = coded.StatusCode()
+		p.Detail = err.Error()
+	}
+	p.Title = http.StatusText(p.Status)
+	c.SetContentType("application/problem+json")
+	c.SetStatus(p.Status)
+	json.NewEncoder(c.Writer).Encode(p)
+}
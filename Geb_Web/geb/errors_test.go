@@ -0,0 +1,100 @@
+package geb
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) ProblemDetails {
+	var p ProblemDetails
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &p))
+	return p
+}
+
+func TestDefaultNotFoundAndNotAllowed(t *testing.T) {
+	e := New()
+	var out strings.Builder
+	e.Use(func(c *Context) {
+		out.WriteString("g")
+	})
+	e.GET("/a", func(c *Context) {})
+
+	w := serve(e, httptest.NewRequest("GET", "/none", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	p := decodeProblem(t, w)
+	assert.Equal(t, ProblemDetails{Type: "about:blank", Title: "Not Found", Status: 404, Detail: "Not Found", Instance: "/none"}, p)
+	assert.Equal(t, "g", out.String())
+
+	w = serve(e, httptest.NewRequest("PUT", "/a", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, 405, decodeProblem(t, w).Status)
+	assert.Equal(t, "gg", out.String())
+}
+
+func TestNoRouteNoMethod(t *testing.T) {
+	e := New()
+	e.GET("/a", func(c *Context) {})
+	e.NoRoute(func(c *Context) {
+		c.Text(http.StatusNotFound, "no route")
+	})
+	e.NoMethod(func(c *Context) {
+		c.Text(http.StatusMethodNotAllowed, "no method "+c.Writer.Header().Get("Allow"))
+	})
+	w := serve(e, httptest.NewRequest("GET", "/none", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "no route", w.Body.String())
+	w = serve(e, httptest.NewRequest("POST", "/a", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "no method GET, HEAD, OPTIONS", w.Body.String())
+}
+
+func TestErrorHandler(t *testing.T) {
+	e := New()
+	e.GET("/internal", func(c *Context) {
+		c.Error(fmt.Errorf("db password is wrong"))
+	})
+	e.GET("/teapot", func(c *Context) {
+		c.Error(&HTTPError{Code: http.StatusTeapot, Message: "short and stout"})
+	})
+	e.GET("/bind", func(c *Context) {
+		var f struct {
+			Name string `form:"name" binding:"required"`
+		}
+		c.Error(c.Bind(&f))
+	})
+	e.GET("/written", func(c *Context) {
+		c.Text(http.StatusOK, "ok")
+		c.Error(fmt.Errorf("ignored"))
+	})
+
+	w := serve(e, httptest.NewRequest("GET", "/internal", nil))
+	p := decodeProblem(t, w)
+	assert.Equal(t, 500, p.Status)
+	assert.Empty(t, p.Detail)
+
+	w = serve(e, httptest.NewRequest("GET", "/teapot", nil))
+	p = decodeProblem(t, w)
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, "short and stout", p.Detail)
+
+	w = serve(e, httptest.NewRequest("GET", "/bind", nil))
+	p = decodeProblem(t, w)
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Equal(t, map[string]string{"name": "name不能为空"}, p.Errors)
+
+	w = serve(e, httptest.NewRequest("GET", "/written", nil))
+	assert.Equal(t, "ok", w.Body.String())
+
+	e.SetErrorHandler(func(c *Context, errs []error) {
+		c.Text(http.StatusBadGateway, fmt.Sprint(len(errs)))
+	})
+	w = serve(e, httptest.NewRequest("GET", "/internal", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "1", w.Body.String())
+}
@@ -0,0 +1,279 @@
+// Package gebtest 测试geb应用的工具：链式的请求构造器、响应断言，
+// 以及不经过路由、在假的Context中单独执行处理函数与中间件
+//
+//	gebtest.New(t, e).POST("/users").JSON(user).Do().
+//		ExpectStatus(http.StatusCreated).
+//		ExpectJSON("data.name", "weiwei")
+package gebtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"geb"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Client 向Engine发送测试请求
+type Client struct {
+	t      testing.TB
+	engine *geb.Engine
+}
+
+// New e为nil时使用一个空的Engine，此时只能通过Request.Run执行处理函数
+func New(t testing.TB, e *geb.Engine) *Client {
+	if e == nil {
+		e = geb.New()
+	}
+	return &Client{t: t, engine: e}
+}
+
+// Request 链式的请求构造器，出错时通过testing.TB终止测试
+type Request struct {
+	cl         *Client
+	method     string
+	target     string
+	header     http.Header
+	query      url.Values
+	cookies    []*http.Cookie
+	body       []byte
+	params     geb.Params
+	remoteAddr string
+}
+
+func (cl *Client) Request(method, target string) *Request {
+	return &Request{cl: cl, method: method, target: target, header: http.Header{}, query: url.Values{}}
+}
+
+func (cl *Client) GET(target string) *Request {
+	return cl.Request(http.MethodGet, target)
+}
+func (cl *Client) POST(target string) *Request {
+	return cl.Request(http.MethodPost, target)
+}
+func (cl *Client) PUT(target string) *Request {
+	return cl.Request(http.MethodPut, target)
+}
+func (cl *Client) DELETE(target string) *Request {
+	return cl.Request(http.MethodDelete, target)
+}
+func (cl *Client) PATCH(target string) *Request {
+	return cl.Request(http.MethodPatch, target)
+}
+
+func (r *Request) Header(key, value string) *Request {
+	r.header.Add(key, value)
+	return r
+}
+
+// Query 追加查询参数，与target中已有的参数合并
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+func (r *Request) Cookie(c *http.Cookie) *Request {
+	r.cookies = append(r.cookies, c)
+	return r
+}
+
+// Body 设置请求体与Content-Type
+func (r *Request) Body(body []byte, contentType string) *Request {
+	r.body = body
+	r.header.Set("Content-Type", contentType)
+	return r
+}
+
+// JSON 将v编码为JSON作为请求体
+func (r *Request) JSON(v interface{}) *Request {
+	r.cl.t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		r.cl.t.Fatalf("gebtest: 无法编码JSON请求体：%v", err)
+	}
+	return r.Body(b, "application/json")
+}
+
+// Form 将values编码为application/x-www-form-urlencoded请求体
+func (r *Request) Form(values url.Values) *Request {
+	return r.Body([]byte(values.Encode()), "application/x-www-form-urlencoded")
+}
+
+// Param 设置路径参数，只对Run有效，Do的路径参数由路由匹配得到
+func (r *Request) Param(key, value string) *Request {
+	r.params = append(r.params, geb.Param{Key: key, Value: value})
+	return r
+}
+
+// RemoteAddr 设置客户端地址，默认为httptest的192.0.2.1:1234
+func (r *Request) RemoteAddr(addr string) *Request {
+	r.remoteAddr = addr
+	return r
+}
+
+// Build 返回构造好的*http.Request
+func (r *Request) Build() *http.Request {
+	target := r.target
+	if len(r.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + r.query.Encode()
+	}
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req := httptest.NewRequest(r.method, target, body)
+	for k, vv := range r.header {
+		req.Header[k] = append(req.Header[k], vv...)
+	}
+	for _, c := range r.cookies {
+		req.AddCookie(c)
+	}
+	if r.remoteAddr != "" {
+		req.RemoteAddr = r.remoteAddr
+	}
+	return req
+}
+
+// Do 通过Engine的路由处理请求
+func (r *Request) Do() *Response {
+	w := httptest.NewRecorder()
+	r.cl.engine.ServeHTTP(w, r.Build())
+	return &Response{ResponseRecorder: w, t: r.cl.t}
+}
+
+// Run 不经过路由，在假的Context中依次执行handlers，
+// 返回的Response.Context可以用来检查中间件保存的值与记录的错误
+func (r *Request) Run(handlers ...geb.HandlerFunc) *Response {
+	w := httptest.NewRecorder()
+	c := r.cl.engine.HandleContext(w, r.Build(), r.params, handlers...)
+	return &Response{ResponseRecorder: w, Context: c, t: r.cl.t}
+}
+
+// Response 测试响应，Expect系列方法失败时通过testing.TB报告错误但不终止测试
+type Response struct {
+	*httptest.ResponseRecorder
+	// Context 只有通过Run得到的响应才有
+	Context *geb.Context
+
+	t       testing.TB
+	decoded interface{}
+	err     error
+	parsed  bool
+}
+
+func (r *Response) ExpectStatus(code int) *Response {
+	r.t.Helper()
+	if r.Code != code {
+		r.t.Errorf("gebtest: 状态码为%d，期望%d，响应体：%s", r.Code, code, r.Body.String())
+	}
+	return r
+}
+
+func (r *Response) ExpectHeader(key, value string) *Response {
+	r.t.Helper()
+	if got := r.Header().Get(key); got != value {
+		r.t.Errorf("gebtest: 响应头%s为%q，期望%q", key, got, value)
+	}
+	return r
+}
+
+func (r *Response) ExpectBody(body string) *Response {
+	r.t.Helper()
+	if got := r.Body.String(); got != body {
+		r.t.Errorf("gebtest: 响应体为%q，期望%q", got, body)
+	}
+	return r
+}
+
+// ExpectJSON 按JSON路径取出响应中的值并与want比较，比较前want会先经过一次JSON编解码，
+// 因此数字可以直接写成Go的整数
+func (r *Response) ExpectJSON(path string, want interface{}) *Response {
+	r.t.Helper()
+	got, err := r.JSONPath(path)
+	if err != nil {
+		r.t.Errorf("gebtest: %v", err)
+		return r
+	}
+	b, err := json.Marshal(want)
+	if err != nil {
+		r.t.Errorf("gebtest: 无法编码期望值：%v", err)
+		return r
+	}
+	var w interface{}
+	json.Unmarshal(b, &w)
+	if !reflect.DeepEqual(got, w) {
+		gb, _ := json.Marshal(got)
+		r.t.Errorf("gebtest: %s为%s，期望%s", path, gb, b)
+	}
+	return r
+}
+
+// DecodeJSON 将响应体按JSON解码到v
+func (r *Response) DecodeJSON(v interface{}) error {
+	return json.Unmarshal(r.Body.Bytes(), v)
+}
+
+// JSONPath 按路径取出响应体中的值，路径以"."分隔，数组使用下标，如"data.items.0.name"；
+// 路径为空或"$"时返回整个响应
+func (r *Response) JSONPath(path string) (interface{}, error) {
+	if !r.parsed {
+		r.parsed = true
+		r.err = json.Unmarshal(r.Body.Bytes(), &r.decoded)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	v := r.decoded
+	if path == "" {
+		return v, nil
+	}
+	for _, p := range strings.Split(path, ".") {
+		switch vv := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = vv[p]; !ok {
+				return nil, &PathError{Path: path, Part: p}
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(vv) {
+				return nil, &PathError{Path: path, Part: p}
+			}
+			v = vv[i]
+		default:
+			return nil, &PathError{Path: path, Part: p}
+		}
+	}
+	return v, nil
+}
+
+// PathError JSON路径中的某一段不存在
+type PathError struct {
+	Path string
+	Part string
+}
+
+func (e *PathError) Error() string {
+	return "JSON路径" + e.Path + "中的" + e.Part + "不存在"
+}
+
+// Cookie 返回响应中名为name的cookie，不存在时返回nil
+func (r *Response) Cookie(name string) *http.Cookie {
+	for _, c := range r.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package gebtest
+
+import (
+	"fmt"
+	"geb"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// 记录断言失败而不是让测试失败
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Fatalf(format string, args ...interface{}) {
+	f.Errorf(format, args...)
+}
+
+type user struct {
+	Name string   `json:"name"`
+	Age  int      `json:"age"`
+	Tags []string `json:"tags"`
+}
+
+func newEngine() *geb.Engine {
+	e := geb.New()
+	e.POST("/users/:group", func(c *geb.Context) {
+		var u user
+		if c.Error(c.BindJSON(&u)) != nil {
+			return
+		}
+		ck, _ := c.Req.Cookie("session")
+		c.SetHeader("X-Group", c.Param("group"))
+		http.SetCookie(c.Writer, &http.Cookie{Name: "seen", Value: ck.Value})
+		c.JSON(http.StatusCreated, map[string]interface{}{
+			"data":  u,
+			"query": c.Req.URL.Query().Get("q"),
+			"token": c.Req.Header.Get("Authorization"),
+		})
+	})
+	e.POST("/form", func(c *geb.Context) {
+		c.Req.ParseForm()
+		c.Text(http.StatusOK, c.Req.PostForm.Get("a"))
+	})
+	return e
+}
+
+func TestDo(t *testing.T) {
+	resp := New(t, newEngine()).POST("/users/admin?x=1").
+		Query("q", "go").
+		Header("Authorization", "Bearer t").
+		Cookie(&http.Cookie{Name: "session", Value: "s1"}).
+		JSON(user{Name: "weiwei", Age: 18, Tags: []string{"a", "b"}}).
+		Do()
+	resp.ExpectStatus(http.StatusCreated).
+		ExpectHeader("X-Group", "admin").
+		ExpectHeader("Content-Type", "application/json").
+		ExpectJSON("data.name", "weiwei").
+		ExpectJSON("data.age", 18).
+		ExpectJSON("$.data.tags.1", "b").
+		ExpectJSON("data.tags", []string{"a", "b"}).
+		ExpectJSON("query", "go").
+		ExpectJSON("token", "Bearer t")
+	assert.Equal(t, "s1", resp.Cookie("seen").Value)
+	assert.Nil(t, resp.Cookie("none"))
+
+	var got struct{ Data user }
+	assert.Nil(t, resp.DecodeJSON(&got))
+	assert.Equal(t, 18, got.Data.Age)
+
+	New(t, newEngine()).POST("/form").Form(url.Values{"a": {"1"}}).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("1")
+}
+
+func TestExpectFailures(t *testing.T) {
+	ft := &fakeT{}
+	resp := New(ft, newEngine()).POST("/users/admin").
+		Cookie(&http.Cookie{Name: "session", Value: "s1"}).
+		JSON(user{Name: "weiwei"}).
+		Do()
+	resp.ExpectStatus(http.StatusOK).
+		ExpectHeader("X-Group", "user").
+		ExpectBody("x").
+		ExpectJSON("data.name", "other").
+		ExpectJSON("data.missing", 1).
+		ExpectJSON("data.tags.5", 1)
+	assert.Len(t, ft.errors, 6)
+
+	_, err := resp.JSONPath("data.name.x")
+	assert.IsType(t, &PathError{}, err)
+
+	ft = &fakeT{}
+	New(ft, nil).GET("/").JSON(make(chan int))
+	assert.Len(t, ft.errors, 1)
+}
+
+func TestRun(t *testing.T) {
+	key := geb.NewKey[string]("user")
+	auth := func(c *geb.Context) {
+		if c.Req.Header.Get("Authorization") == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		key.Set(c, "weiwei")
+	}
+	handler := func(c *geb.Context) {
+		c.Text(http.StatusOK, key.MustGet(c)+" "+c.Param("id")+" "+c.ClientIP())
+	}
+
+	cl := New(t, nil)
+	resp := cl.GET("/any").Param("id", "7").RemoteAddr("10.0.0.1:80").
+		Header("Authorization", "x").
+		Run(auth, handler)
+	resp.ExpectStatus(http.StatusOK).ExpectBody("weiwei 7 10.0.0.1")
+	v, ok := key.Get(resp.Context)
+	assert.True(t, ok)
+	assert.Equal(t, "weiwei", v)
+
+	resp = cl.GET("/any").Run(auth, handler)
+	resp.ExpectStatus(http.StatusUnauthorized)
+	assert.True(t, resp.Context.IsAborted())
+
+	// 记录的错误与ServeHTTP一样交给ErrorHandler
+	resp = cl.GET("/any").Run(func(c *geb.Context) {
+		c.Error(geb.NewHTTPError(http.StatusTeapot, ""))
+	})
+	resp.ExpectStatus(http.StatusTeapot).
+		ExpectHeader("Content-Type", "application/problem+json").
+		ExpectJSON("status", http.StatusTeapot)
+	assert.Len(t, resp.Context.Errors(), 1)
+}
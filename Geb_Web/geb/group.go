@@ -0,0 +1,117 @@
+package geb
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RouterGroup 路由组，组内注册的模式都会加上prefix前缀
+//
+// 组的中间件只作用于通过该组（及其子组）注册的路由，注册时放在处理函数之前；
+// 前缀相同但不经过该组注册的路由不受影响。Engine本身的中间件挂在路由树的根节点上，
+// 作用于全部请求，包括没有匹配到路由的请求
+type RouterGroup struct {
+	prefix      string
+	engine      *Engine
+	middlewires []HandlerFunc
+}
+
+// Group 创建一个子路由组，prefix必须以"/"开头，可以包含命名参数但不能包含"*"或"."，
+// prefix非法时panic
+func (g *RouterGroup) Group(prefix string, middlewires ...HandlerFunc) *RouterGroup {
+	if len(prefix) == 0 || prefix[0] != '/' {
+		panic(fmt.Sprintf("路由组前缀必须以/开头：%s", prefix))
+	}
+	if strings.Contains(prefix, "*") || strings.Contains(prefix, ".") {
+		panic(fmt.Sprintf("路由组前缀不能包含*或.：%s", prefix))
+	}
+	ng := &RouterGroup{prefix: joinPaths(g.prefix, prefix), engine: g.engine}
+	// 子组继承创建时父组的中间件，之后再添加到父组的不会影响子组
+	ng.middlewires = append(ng.middlewires, g.middlewires...)
+	ng.middlewires = append(ng.middlewires, middlewires...)
+	return ng
+}
+
+// Use 为组添加中间件，只作用于之后通过该组注册的路由；
+// 在Engine上调用时作用于全部请求
+func (g *RouterGroup) Use(middlewires ...HandlerFunc) error {
+	if g == g.engine.RouterGroup {
+		return g.engine.router.AddMiddlewire("/", middlewires...)
+	}
+	g.middlewires = append(g.middlewires, middlewires...)
+	return nil
+}
+
+// Prefix 返回组的完整前缀
+func (g *RouterGroup) Prefix() string {
+	return g.prefix
+}
+
+// Handle 为method注册处理函数，method可以是任意标准HTTP方法
+func (g *RouterGroup) Handle(method, patten string, handler HandlerFunc) error {
+	patten = joinPaths(g.prefix, patten)
+	if len(g.middlewires) == 0 {
+		return g.engine.router.AddHandler(method, patten, handler)
+	}
+	handlers := make([]HandlerFunc, 0, len(g.middlewires)+1)
+	handlers = append(append(handlers, g.middlewires...), handler)
+	if r, ok := g.engine.router.(chainRouter); ok {
+		return r.addRoute(method, patten, handlers)
+	}
+	return g.engine.router.AddHandler(method, patten, chain(handlers))
+}
+
+// chain 把一串处理函数合成一个，内部的Next与Abort与直接注册时相同
+func chain(handlers []HandlerFunc) HandlerFunc {
+	return func(c *Context) {
+		outer, i := c.handlers, c.i
+		c.handlers, c.i = handlers, 0
+		c.run()
+		c.handlers, c.i = outer, i
+	}
+}
+
+func (g *RouterGroup) GET(patten string, handler HandlerFunc) error {
+	return g.Handle(http.MethodGet, patten, handler)
+}
+func (g *RouterGroup) POST(patten string, handler HandlerFunc) error {
+	return g.Handle(http.MethodPost, patten, handler)
+}
+func (g *RouterGroup) PUT(patten string, handler HandlerFunc) error {
+	return g.Handle(http.MethodPut, patten, handler)
+}
+func (g *RouterGroup) DELETE(patten string, handler HandlerFunc) error {
+	return g.Handle(http.MethodDelete, patten, handler)
+}
+func (g *RouterGroup) PATCH(patten string, handler HandlerFunc) error {
+	return g.Handle(http.MethodPatch, patten, handler)
+}
+func (g *RouterGroup) HEAD(patten string, handler HandlerFunc) error {
+	return g.Handle(http.MethodHead, patten, handler)
+}
+func (g *RouterGroup) OPTIONS(patten string, handler HandlerFunc) error {
+	return g.Handle(http.MethodOptions, patten, handler)
+}
+
+// Any 为全部标准方法注册同一个处理函数
+func (g *RouterGroup) Any(patten string, handler HandlerFunc) error {
+	for _, m := range methods {
+		if err := g.Handle(m, patten, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// joinPaths 拼接组前缀与模式，/api + / = /api，/api/ + /users = /api/users
+func joinPaths(prefix, patten string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return patten
+	}
+	if patten == "/" || patten == "" {
+		return prefix
+	}
+	return prefix + patten
+}
@@ -0,0 +1,137 @@
+package geb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJoinPaths(t *testing.T) {
+	assert.Equal(t, "/a", joinPaths("", "/a"))
+	assert.Equal(t, "/api", joinPaths("/api", "/"))
+	assert.Equal(t, "/api/users", joinPaths("/api/", "/users"))
+	assert.Equal(t, "/api/v1/users", joinPaths("/api/v1", "/users"))
+}
+
+func TestRouterGroup(t *testing.T) {
+	e := New()
+	var out strings.Builder
+	e.Use(func(c *Context) {
+		out.WriteString("g")
+	})
+	api := e.Group("/api/v1", func(c *Context) {
+		out.WriteString("a")
+	})
+	api.GET("/users/:id", func(c *Context) {
+		c.Text(http.StatusOK, c.Param("id"))
+	})
+	admin := api.Group("/admin")
+	admin.Use(func(c *Context) {
+		if c.Req.Header.Get("Token") == "" {
+			c.Fail(http.StatusUnauthorized)
+		}
+	})
+	admin.DELETE("/users/:id", func(c *Context) {
+		c.Text(http.StatusOK, "deleted "+c.Param("id"))
+	})
+	assert.Equal(t, "/api/v1/admin", admin.Prefix())
+
+	req := httptest.NewRequest("GET", "/api/v1/users/3", nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Body.String())
+	assert.Equal(t, "ga", out.String())
+
+	req = httptest.NewRequest("DELETE", "/api/v1/admin/users/3", nil)
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req = httptest.NewRequest("DELETE", "/api/v1/admin/users/3", nil)
+	req.Header.Set("Token", "x")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "deleted 3", w.Body.String())
+
+	assert.Panics(t, func() { e.Group("api") })
+	assert.Panics(t, func() { e.Group("/static/*") })
+}
+
+// 组的中间件只作用于通过该组注册的路由
+func TestRouterGroupScope(t *testing.T) {
+	e := New()
+	var out []string
+	mw := func(name string) HandlerFunc {
+		return func(c *Context) {
+			out = append(out, name)
+			c.Next()
+			out = append(out, name+"-after")
+		}
+	}
+	api := e.Group("/api", mw("auth"))
+	api.GET("/private", func(c *Context) { out = append(out, "private") })
+	// 前缀相同，但不经过api注册
+	e.GET("/api/public", func(c *Context) { out = append(out, "public") })
+	other := e.Group("/api")
+	other.GET("/other", func(c *Context) { out = append(out, "other") })
+	// 子组继承父组的中间件，子组的中间件不影响父组
+	v1 := api.Group("/v1", mw("v1"))
+	v1.GET("/users", func(c *Context) { out = append(out, "users") })
+	api.GET("/later", func(c *Context) { out = append(out, "later") })
+
+	run := func(path string) []string {
+		out = nil
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		return out
+	}
+	assert.Equal(t, []string{"auth", "private", "auth-after"}, run("/api/private"))
+	assert.Equal(t, []string{"public"}, run("/api/public"))
+	assert.Equal(t, []string{"other"}, run("/api/other"))
+	assert.Equal(t, []string{"auth", "v1", "users", "v1-after", "auth-after"}, run("/api/v1/users"))
+	assert.Equal(t, []string{"auth", "later", "auth-after"}, run("/api/later"))
+
+	// 中间件终止时不执行处理函数
+	deny := e.Group("/deny", func(c *Context) { c.Fail(http.StatusForbidden) })
+	deny.GET("/x", func(c *Context) { out = append(out, "x") })
+	out = nil
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/deny/x", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, out)
+
+	for _, r := range e.Routes() {
+		if r.Patten == "/api/v1/users" {
+			assert.Equal(t, 2, r.Middlewires)
+			assert.Contains(t, r.Handler, "TestRouterGroupScope")
+		}
+	}
+}
+
+// 只实现Router接口的路由收到合成后的处理函数
+type plainRouter struct {
+	Router
+}
+
+func TestRouterGroupChain(t *testing.T) {
+	e := New()
+	e.router = plainRouter{&defaultRouter{}}
+	var out []string
+	g := e.Group("/g", func(c *Context) {
+		out = append(out, "a")
+		c.Next()
+		out = append(out, "a-after")
+	}, func(c *Context) {
+		out = append(out, "b")
+	})
+	g.GET("/x", func(c *Context) { out = append(out, "x") })
+	e.GET("/y", func(c *Context) { out = append(out, "y") })
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/g/x", nil))
+	assert.Equal(t, []string{"a", "b", "x", "a-after"}, out)
+}
@@ -0,0 +1,259 @@
+package geb
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// htmlRender 模板注册表
+//
+// 支持两种加载方式：
+//
+// 1，LoadHTMLGlob/LoadHTMLFiles，全部文件解析到同一个模板集合中，模板名为文件名，
+// 文件之间可以通过{{template "name" .}}互相引用
+//
+// 2，LoadHTMLLayout，layouts中的布局与局部模板被复制给每一个页面单独解析，
+// 因此不同页面可以各自定义同名的块（例如"content"）而互不覆盖
+//
+// 开发模式下每次渲染前都会检查文件是否有增删或修改，有变化时重新解析
+type htmlRender struct {
+	mu sync.RWMutex
+
+	templ *template.Template
+	pages map[string]*template.Template
+	funcs template.FuncMap
+
+	// 加载配置，用于开发模式下重新解析
+	globs   []string
+	files   []string
+	layouts string
+	dev     bool
+	modTime map[string]time.Time
+}
+
+func (h *htmlRender) setFuncs(funcs template.FuncMap) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.funcs == nil {
+		h.funcs = template.FuncMap{}
+	}
+	for k, f := range funcs {
+		h.funcs[k] = f
+	}
+}
+
+func (h *htmlRender) setTemplate(templ *template.Template) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.templ = templ
+	h.pages = nil
+	h.globs, h.files, h.layouts = nil, nil, ""
+	h.modTime = nil
+}
+
+func (h *htmlRender) load(globs []string, files []string, layouts string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.globs, h.files, h.layouts = globs, files, layouts
+	return h.reload()
+}
+
+// 调用方需持有写锁
+func (h *htmlRender) reload() error {
+	pageFiles, err := h.matchFiles()
+	if err != nil {
+		return err
+	}
+	if len(pageFiles) == 0 {
+		return fmt.Errorf("没有匹配的模板文件")
+	}
+	modTime := make(map[string]time.Time)
+	for _, f := range pageFiles {
+		info, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		modTime[f] = info.ModTime()
+	}
+	var layoutFiles []string
+	if h.layouts != "" {
+		if layoutFiles, err = filepath.Glob(h.layouts); err != nil {
+			return err
+		}
+		for _, f := range layoutFiles {
+			info, err := os.Stat(f)
+			if err != nil {
+				return err
+			}
+			modTime[f] = info.ModTime()
+		}
+	}
+
+	root := template.New("").Funcs(h.funcs)
+	if len(layoutFiles) == 0 {
+		templ, err := root.ParseFiles(pageFiles...)
+		if err != nil {
+			return err
+		}
+		h.templ, h.pages = templ, nil
+	} else {
+		base, err := root.ParseFiles(layoutFiles...)
+		if err != nil {
+			return err
+		}
+		pages := make(map[string]*template.Template, len(pageFiles))
+		for _, f := range pageFiles {
+			clone, err := base.Clone()
+			if err != nil {
+				return err
+			}
+			if pages[filepath.Base(f)], err = clone.ParseFiles(f); err != nil {
+				return err
+			}
+		}
+		h.templ, h.pages = base, pages
+	}
+	h.modTime = modTime
+	return nil
+}
+
+func (h *htmlRender) matchFiles() ([]string, error) {
+	files := append([]string(nil), h.files...)
+	for _, g := range h.globs {
+		matches, err := filepath.Glob(g)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// 文件有增删或修改时返回true
+func (h *htmlRender) changed() bool {
+	if h.modTime == nil {
+		return false
+	}
+	files, err := h.matchFiles()
+	if err != nil {
+		return true
+	}
+	if h.layouts != "" {
+		layouts, err := filepath.Glob(h.layouts)
+		if err != nil {
+			return true
+		}
+		files = append(files, layouts...)
+	}
+	if len(files) != len(h.modTime) {
+		return true
+	}
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return true
+		}
+		if t, ok := h.modTime[f]; !ok || !t.Equal(info.ModTime()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *htmlRender) execute(w io.Writer, name string, data interface{}) error {
+	h.mu.RLock()
+	dev := h.dev
+	h.mu.RUnlock()
+	if dev {
+		h.mu.Lock()
+		if h.changed() {
+			if err := h.reload(); err != nil {
+				h.mu.Unlock()
+				return err
+			}
+		}
+		h.mu.Unlock()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if page, ok := h.pages[name]; ok {
+		return page.ExecuteTemplate(w, name, data)
+	}
+	if h.templ == nil {
+		return fmt.Errorf("未加载模板")
+	}
+	return h.templ.ExecuteTemplate(w, name, data)
+}
+
+// SetFuncMap 注册模板函数，需要在加载模板之前调用
+func (e *Engine) SetFuncMap(funcs template.FuncMap) error {
+	if err := hasRun(e); err != nil {
+		return err
+	}
+	e.html.setFuncs(funcs)
+	return nil
+}
+
+// LoadHTMLGlob 加载匹配pattern的全部模板文件
+func (e *Engine) LoadHTMLGlob(pattern string) error {
+	if err := hasRun(e); err != nil {
+		return err
+	}
+	return e.html.load([]string{pattern}, nil, "")
+}
+
+// LoadHTMLFiles 加载指定的模板文件
+func (e *Engine) LoadHTMLFiles(files ...string) error {
+	if err := hasRun(e); err != nil {
+		return err
+	}
+	return e.html.load(nil, files, "")
+}
+
+// LoadHTMLLayout 以布局方式加载模板，layouts匹配布局与局部模板，pages匹配页面，
+// 渲染时使用页面的文件名，例如：
+//
+// layouts/base.html：<html>{{block "content" .}}{{end}}</html>
+//
+// pages/index.html：{{define "content"}}hello{{end}}{{template "base.html" .}}
+//
+// c.HTML(200, "index.html", nil)
+func (e *Engine) LoadHTMLLayout(layouts, pages string) error {
+	if err := hasRun(e); err != nil {
+		return err
+	}
+	return e.html.load([]string{pages}, nil, layouts)
+}
+
+// SetDevMode 开发模式下模板文件发生变化后会在下一次渲染时重新解析
+func (e *Engine) SetDevMode(dev bool) error {
+	if err := hasRun(e); err != nil {
+		return err
+	}
+	e.html.mu.Lock()
+	e.html.dev = dev
+	e.html.mu.Unlock()
+	return nil
+}
+
+// HTML 渲染名为name的模板，渲染出错时不会写出任何数据
+func (c *Context) HTML(status int, name string, data interface{}) error {
+	if c.engine == nil {
+		return fmt.Errorf("未加载模板")
+	}
+	var buf bytes.Buffer
+	if err := c.engine.html.execute(&buf, name, data); err != nil {
+		return err
+	}
+	c.SetContentType("text/html; charset=utf-8")
+	c.SetStatus(status)
+	_, err := c.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,77 @@
+package geb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func render(e *Engine, name string, data interface{}) *httptest.ResponseRecorder {
+	e.GET("/"+name, func(c *Context) {
+		if err := c.HTML(http.StatusOK, name, data); err != nil {
+			c.Text(http.StatusInternalServerError, err.Error())
+		}
+	})
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/"+name, nil))
+	return w
+}
+
+func TestHTMLGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "index.html"), `<p>{{upper .}}</p>{{template "footer.html"}}`)
+	writeFile(t, filepath.Join(dir, "footer.html"), `<footer>geb</footer>`)
+	e := New()
+	assert.Nil(t, e.SetFuncMap(template.FuncMap{"upper": strings.ToUpper}))
+	assert.Nil(t, e.LoadHTMLGlob(filepath.Join(dir, "*.html")))
+	w := render(e, "index.html", "<b>")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "<p>&lt;B&gt;</p><footer>geb</footer>", w.Body.String())
+
+	w = render(e, "none.html", nil)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestHTMLLayout(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "layouts", "base.html"), `<html>{{block "content" .}}{{end}}</html>`)
+	writeFile(t, filepath.Join(dir, "pages", "a.html"), `{{define "content"}}a{{.}}{{end}}{{template "base.html" .}}`)
+	writeFile(t, filepath.Join(dir, "pages", "b.html"), `{{define "content"}}b{{.}}{{end}}{{template "base.html" .}}`)
+	e := New()
+	assert.Nil(t, e.LoadHTMLLayout(filepath.Join(dir, "layouts", "*"), filepath.Join(dir, "pages", "*")))
+	assert.Equal(t, "<html>a1</html>", render(e, "a.html", 1).Body.String())
+	assert.Equal(t, "<html>b2</html>", render(e, "b.html", 2).Body.String())
+}
+
+func TestHTMLDevMode(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "index.html")
+	writeFile(t, name, `v1`)
+	e := New()
+	assert.Nil(t, e.SetDevMode(true))
+	assert.Nil(t, e.LoadHTMLGlob(filepath.Join(dir, "*.html")))
+	assert.Equal(t, "v1", render(e, "index.html", nil).Body.String())
+
+	writeFile(t, name, `v2`)
+	later := time.Now().Add(time.Second)
+	assert.Nil(t, os.Chtimes(name, later, later))
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/index.html", nil))
+	assert.Equal(t, "v2", w.Body.String())
+}
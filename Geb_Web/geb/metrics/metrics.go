@@ -0,0 +1,292 @@
+// Package metrics 记录请求数、延迟分布与正在处理的请求数，并以Prometheus文本格式输出，
+// 不依赖Prometheus的客户端库
+//
+//	m := metrics.New(metrics.Config{})
+//	e.Use(m.Middlewire())
+//	e.GET("/metrics", m.Handler())
+package metrics
+
+import (
+	"geb"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config 指标配置
+type Config struct {
+	// Namespace 指标名前缀，默认为geb
+	Namespace string
+	// Buckets 延迟直方图的上界，单位为秒，默认与Prometheus客户端的DefBuckets相同
+	Buckets []float64
+}
+
+// DefaultBuckets 默认的延迟直方图上界
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// 没有匹配到路由的请求使用的route标签，避免原始路径导致标签值无限增长
+const unmatched = "unmatched"
+
+// Metrics 一组HTTP指标，可以同时在多个Engine中使用
+type Metrics struct {
+	conf Config
+
+	mu        sync.RWMutex
+	requests  map[requestKey]*uint64
+	durations map[routeKey]*histogram
+	inFlight  map[routeKey]*int64
+}
+
+type routeKey struct {
+	method string
+	route  string
+}
+
+type requestKey struct {
+	routeKey
+	status int
+}
+
+type histogram struct {
+	// 每个桶单独计数，输出时再累加
+	counts []uint64
+	count  uint64
+	// float64的位表示
+	sum uint64
+}
+
+func New(conf Config) *Metrics {
+	if conf.Namespace == "" {
+		conf.Namespace = "geb"
+	}
+	if len(conf.Buckets) == 0 {
+		conf.Buckets = DefaultBuckets
+	}
+	buckets := append([]float64(nil), conf.Buckets...)
+	sort.Float64s(buckets)
+	conf.Buckets = buckets
+	return &Metrics{
+		conf:      conf,
+		requests:  make(map[requestKey]*uint64),
+		durations: make(map[routeKey]*histogram),
+		inFlight:  make(map[routeKey]*int64),
+	}
+}
+
+// Middlewire 记录指标的中间件，route标签使用Context.Patten，
+// 因此需要挂在路由匹配之后执行的位置，通常通过Engine.Use注册
+func (m *Metrics) Middlewire() geb.HandlerFunc {
+	return func(c *geb.Context) {
+		route := c.Patten()
+		if route == "" {
+			route = unmatched
+		}
+		key := routeKey{method: methodLabel(c.Req.Method), route: route}
+		g := m.gauge(key)
+		atomic.AddInt64(g, 1)
+		start := time.Now()
+		defer func() {
+			atomic.AddInt64(g, -1)
+			m.histogram(key).observe(time.Since(start).Seconds(), m.conf.Buckets)
+			status := c.Writer.Status()
+			if !c.Writer.Written() && len(c.Errors()) > 0 {
+				// 错误响应由处理链结束后的ErrorHandler写出，此处按错误的状态码记录
+				status = errorStatus(c.Errors())
+			}
+			atomic.AddUint64(m.counter(requestKey{routeKey: key, status: status}), 1)
+		}()
+		c.Next()
+	}
+}
+
+// 其他方法使用的method标签，与未匹配的路由一样避免客户端构造出任意多的序列
+const otherMethod = "OTHER"
+
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+func methodLabel(method string) string {
+	if standardMethods[method] {
+		return method
+	}
+	return otherMethod
+}
+
+func errorStatus(errs []error) int {
+	if sc, ok := errs[len(errs)-1].(interface{ StatusCode() int }); ok {
+		return sc.StatusCode()
+	}
+	return http.StatusInternalServerError
+}
+
+func (m *Metrics) counter(key requestKey) *uint64 {
+	m.mu.RLock()
+	c, ok := m.requests[key]
+	m.mu.RUnlock()
+	if ok {
+		return c
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok = m.requests[key]; !ok {
+		c = new(uint64)
+		m.requests[key] = c
+	}
+	return c
+}
+
+func (m *Metrics) gauge(key routeKey) *int64 {
+	m.mu.RLock()
+	g, ok := m.inFlight[key]
+	m.mu.RUnlock()
+	if ok {
+		return g
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if g, ok = m.inFlight[key]; !ok {
+		g = new(int64)
+		m.inFlight[key] = g
+	}
+	return g
+}
+
+func (m *Metrics) histogram(key routeKey) *histogram {
+	m.mu.RLock()
+	h, ok := m.durations[key]
+	m.mu.RUnlock()
+	if ok {
+		return h
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h, ok = m.durations[key]; !ok {
+		h = &histogram{counts: make([]uint64, len(m.conf.Buckets))}
+		m.durations[key] = h
+	}
+	return h
+}
+
+func (h *histogram) observe(v float64, buckets []float64) {
+	if i := sort.SearchFloat64s(buckets, v); i < len(buckets) {
+		atomic.AddUint64(&h.counts[i], 1)
+	}
+	for {
+		old := atomic.LoadUint64(&h.sum)
+		sum := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&h.sum, old, sum) {
+			break
+		}
+	}
+	atomic.AddUint64(&h.count, 1)
+}
+
+// Handler 以Prometheus文本格式输出全部指标
+func (m *Metrics) Handler() geb.HandlerFunc {
+	return func(c *geb.Context) {
+		c.SetContentType("text/plain; version=0.0.4; charset=utf-8")
+		c.Data(http.StatusOK, []byte(m.String()))
+	}
+}
+
+// String 返回Prometheus文本格式的全部指标，序列按标签排序，输出稳定
+func (m *Metrics) String() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var b strings.Builder
+	ns := m.conf.Namespace
+
+	name := ns + "_http_requests_total"
+	b.WriteString("# HELP " + name + " Total number of HTTP requests.\n")
+	b.WriteString("# TYPE " + name + " counter\n")
+	reqKeys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		reqKeys = append(reqKeys, k)
+	}
+	sort.Slice(reqKeys, func(i, j int) bool {
+		if reqKeys[i].routeKey != reqKeys[j].routeKey {
+			return reqKeys[i].routeKey.less(reqKeys[j].routeKey)
+		}
+		return reqKeys[i].status < reqKeys[j].status
+	})
+	for _, k := range reqKeys {
+		writeSample(&b, name, k.labels("code", strconv.Itoa(k.status)), float64(atomic.LoadUint64(m.requests[k])))
+	}
+
+	name = ns + "_http_request_duration_seconds"
+	b.WriteString("# HELP " + name + " HTTP request latency in seconds.\n")
+	b.WriteString("# TYPE " + name + " histogram\n")
+	for _, k := range sortedKeys(m.durations) {
+		h := m.durations[k]
+		var cum uint64
+		for i, le := range m.conf.Buckets {
+			cum += atomic.LoadUint64(&h.counts[i])
+			writeSample(&b, name+"_bucket", k.labels("le", formatFloat(le)), float64(cum))
+		}
+		count := atomic.LoadUint64(&h.count)
+		writeSample(&b, name+"_bucket", k.labels("le", "+Inf"), float64(count))
+		writeSample(&b, name+"_sum", k.labels(), math.Float64frombits(atomic.LoadUint64(&h.sum)))
+		writeSample(&b, name+"_count", k.labels(), float64(count))
+	}
+
+	name = ns + "_http_requests_in_flight"
+	b.WriteString("# HELP " + name + " Number of HTTP requests being served.\n")
+	b.WriteString("# TYPE " + name + " gauge\n")
+	for _, k := range sortedKeys(m.inFlight) {
+		writeSample(&b, name, k.labels(), float64(atomic.LoadInt64(m.inFlight[k])))
+	}
+	return b.String()
+}
+
+func sortedKeys[V any](m map[routeKey]V) []routeKey {
+	keys := make([]routeKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+	return keys
+}
+
+func (k routeKey) less(o routeKey) bool {
+	if k.route != o.route {
+		return k.route < o.route
+	}
+	return k.method < o.method
+}
+
+// 返回格式化后的标签，extra为额外的键值对
+func (k routeKey) labels(extra ...string) string {
+	s := `method="` + escape(k.method) + `",route="` + escape(k.route) + `"`
+	for i := 0; i+1 < len(extra); i += 2 {
+		s += "," + extra[i] + `="` + escape(extra[i+1]) + `"`
+	}
+	return s
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeSample(b *strings.Builder, name, labels string, v float64) {
+	b.WriteString(name + "{" + labels + "} " + formatFloat(v) + "\n")
+}
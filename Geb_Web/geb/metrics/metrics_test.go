@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"geb"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(e *geb.Engine, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestMetrics(t *testing.T) {
+	m := New(Config{Buckets: []float64{1, 0.5}})
+	e := geb.New()
+	assert.Nil(t, e.Use(m.Middlewire()))
+	assert.Nil(t, e.GET("/users/:id", func(c *geb.Context) {
+		c.Text(http.StatusOK, c.Param("id"))
+	}))
+	assert.Nil(t, e.POST("/users", func(c *geb.Context) {
+		c.Error(geb.NewHTTPError(http.StatusConflict, ""))
+	}))
+	assert.Nil(t, e.GET("/metrics", m.Handler()))
+
+	serve(e, "GET", "/users/1")
+	serve(e, "GET", "/users/2")
+	serve(e, "POST", "/users")
+	serve(e, "GET", "/nothing/1")
+	serve(e, "GET", "/nothing/2")
+	serve(e, "FOO1", "/users/1")
+	serve(e, "FOO2", "/nothing/3")
+	serve(e, "get", "/users/1")
+
+	w := serve(e, "GET", "/metrics")
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE geb_http_requests_total counter",
+		`geb_http_requests_total{method="GET",route="/users/:id",code="200"} 2`,
+		`geb_http_requests_total{method="POST",route="/users",code="409"} 1`,
+		`geb_http_requests_total{method="GET",route="unmatched",code="404"} 2`,
+		// 非标准的方法统一记为OTHER
+		`geb_http_requests_total{method="OTHER",route="unmatched",code="404"} 1`,
+		`geb_http_requests_total{method="OTHER",route="unmatched",code="405"} 2`,
+		"# TYPE geb_http_request_duration_seconds histogram",
+		`geb_http_request_duration_seconds_bucket{method="GET",route="/users/:id",le="0.5"} 2`,
+		`geb_http_request_duration_seconds_bucket{method="GET",route="/users/:id",le="1"} 2`,
+		`geb_http_request_duration_seconds_bucket{method="GET",route="/users/:id",le="+Inf"} 2`,
+		`geb_http_request_duration_seconds_count{method="GET",route="/users/:id"} 2`,
+		"# TYPE geb_http_requests_in_flight gauge",
+		`geb_http_requests_in_flight{method="GET",route="/users/:id"} 0`,
+		// 正在处理的/metrics请求本身
+		`geb_http_requests_in_flight{method="GET",route="/metrics"} 1`,
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+	assert.NotContains(t, body, "/users/1")
+	assert.NotContains(t, body, "FOO")
+	assert.NotContains(t, body, `method="get"`)
+	assert.Contains(t, body, `geb_http_request_duration_seconds_sum{method="GET",route="/users/:id"} `)
+}
+
+func TestHistogram(t *testing.T) {
+	m := New(Config{Namespace: "app", Buckets: []float64{0.1, 1}})
+	h := m.histogram(routeKey{method: "GET", route: "/"})
+	for _, v := range []float64{0.05, 0.1, 0.5, 2} {
+		h.observe(v, m.conf.Buckets)
+	}
+	s := m.String()
+	assert.Contains(t, s, `app_http_request_duration_seconds_bucket{method="GET",route="/",le="0.1"} 2`+"\n")
+	assert.Contains(t, s, `app_http_request_duration_seconds_bucket{method="GET",route="/",le="1"} 3`+"\n")
+	assert.Contains(t, s, `app_http_request_duration_seconds_bucket{method="GET",route="/",le="+Inf"} 4`+"\n")
+	assert.Contains(t, s, `app_http_request_duration_seconds_sum{method="GET",route="/"} 2.65`+"\n")
+	assert.Equal(t, `method="a\"b\\c\n",route="/"`, routeKey{method: "a\"b\\c\n", route: "/"}.labels())
+}
+
+func TestPprof(t *testing.T) {
+	e := geb.New()
+	assert.Nil(t, Pprof(e.Group("/debug/pprof")))
+	w := serve(e, "GET", "/debug/pprof/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), "goroutine"))
+
+	w = serve(e, "GET", "/debug/pprof/goroutine?debug=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine profile")
+
+	w = serve(e, "GET", "/debug/pprof/cmdline")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(e, "GET", "/debug/pprof/unknown")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"geb"
+	"net/http"
+	"net/http/pprof"
+)
+
+// Pprof 在路由组下注册net/http/pprof的处理函数，通常挂在带鉴权的组上：
+//
+//	metrics.Pprof(e.Group("/debug/pprof", adminOnly))
+//
+// 组前缀下的"/"为索引页，"/:name"为heap、goroutine等命名的profile
+func Pprof(g *geb.RouterGroup) error {
+	routes := []struct {
+		method  string
+		patten  string
+		handler http.Handler
+	}{
+		{http.MethodGet, "/", http.HandlerFunc(pprof.Index)},
+		{http.MethodGet, "/cmdline", http.HandlerFunc(pprof.Cmdline)},
+		{http.MethodGet, "/profile", http.HandlerFunc(pprof.Profile)},
+		{http.MethodGet, "/symbol", http.HandlerFunc(pprof.Symbol)},
+		{http.MethodPost, "/symbol", http.HandlerFunc(pprof.Symbol)},
+		{http.MethodGet, "/trace", http.HandlerFunc(pprof.Trace)},
+		{http.MethodGet, "/:name", nil},
+	}
+	for _, r := range routes {
+		h := r.handler
+		var handler geb.HandlerFunc
+		if h == nil {
+			handler = func(c *geb.Context) {
+				pprof.Handler(c.Param("name")).ServeHTTP(c.Writer, c.Req)
+			}
+		} else {
+			handler = func(c *geb.Context) {
+				h.ServeHTTP(c.Writer, c.Req)
+			}
+		}
+		if err := g.Handle(r.method, r.patten, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package geb
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// 内置中间件，通过Engine.Use、RouterGroup.Use或AddMiddlewire注册，例如：
+//
+// e.Use(geb.RequestID(), geb.Logger(), geb.Recovery())
+
+// Recovery 捕获之后的处理函数中的panic，打印调用栈并返回500，
+// 如果响应已经开始写出则只终止处理链
+func Recovery() HandlerFunc {
+	return RecoveryWithWriter(os.Stderr)
+}
+
+// RecoveryWithWriter 与Recovery相同，调用栈写入out
+func RecoveryWithWriter(out io.Writer) HandlerFunc {
+	logger := log.New(out, "[geb] ", log.LstdFlags)
+	return func(c *Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Printf("panic: %v %s %s\n%s", err, c.Req.Method, c.Req.URL.Path, debug.Stack())
+				if c.Writer.Written() {
+					c.Abort()
+				} else {
+					c.Fail(http.StatusInternalServerError)
+				}
+			}
+		}()
+		c.Next()
+	}
+}
+
+// LoggerConfig 访问日志配置
+type LoggerConfig struct {
+	// Output 日志输出，默认为os.Stdout
+	Output io.Writer
+	// SkipPaths 不记录日志的路径，例如健康检查
+	SkipPaths []string
+}
+
+// Logger 记录访问日志，每个请求一行key=value格式的记录：
+//
+// time=2006-01-02T15:04:05Z07:00 method=GET path=/a status=200 latency=1.2ms bytes=5 ip=127.0.0.1 request_id=xxx
+func Logger() HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{})
+}
+
+func LoggerWithConfig(conf LoggerConfig) HandlerFunc {
+	out := conf.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	skip := make(map[string]bool, len(conf.SkipPaths))
+	for _, p := range conf.SkipPaths {
+		skip[p] = true
+	}
+	return func(c *Context) {
+		start := time.Now()
+		path := c.Req.URL.Path
+		c.Next()
+		if skip[path] {
+			return
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "time=%s method=%s path=%q status=%d latency=%s bytes=%d ip=%s",
+			start.Format(time.RFC3339), c.Req.Method, path, c.Writer.Status(),
+			time.Since(start), c.Writer.Size(), c.ClientIP())
+		if id := c.RequestID(); id != "" {
+			fmt.Fprintf(&b, " request_id=%s", id)
+		}
+		b.WriteByte('\n')
+		io.WriteString(out, b.String())
+	}
+}
+
+// HeaderRequestID 请求ID使用的请求头与响应头
+const HeaderRequestID = "X-Request-ID"
+
+// RequestID 从请求头X-Request-ID读取请求ID，没有或不合法时生成一个新的，
+// 请求ID会写入响应头，并可以通过Context.RequestID获取
+func RequestID() HandlerFunc {
+	return func(c *Context) {
+		id := c.Req.Header.Get(HeaderRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.requestID = id
+		c.SetHeader(HeaderRequestID, id)
+		c.Next()
+	}
+}
+
+// 请求ID由客户端提供，会原样写入日志，只接受不超过64个字符的字母、数字与"-_.:"，
+// 避免空格、引号、换行等伪造日志字段
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		ch := id[i]
+		if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' ||
+			ch == '-' || ch == '_' || ch == '.' || ch == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+func clientIP(req *http.Request) string {
+	addr := req.RemoteAddr
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		addr = addr[:i]
+	}
+	return strings.Trim(addr, "[]")
+}
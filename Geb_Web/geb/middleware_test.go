@@ -0,0 +1,89 @@
+package geb
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecovery(t *testing.T) {
+	var out bytes.Buffer
+	e := New()
+	e.Use(RecoveryWithWriter(&out))
+	e.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+	e.GET("/half", func(c *Context) {
+		c.Text(http.StatusAccepted, "half")
+		panic("boom")
+	})
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, out.String(), "panic: boom GET /panic")
+	assert.Contains(t, out.String(), "middleware_test.go")
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/half", nil))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "half", w.Body.String())
+}
+
+func TestLoggerAndRequestID(t *testing.T) {
+	var out bytes.Buffer
+	e := New()
+	e.Use(RequestID(), LoggerWithConfig(LoggerConfig{Output: &out, SkipPaths: []string{"/health"}}))
+	e.GET("/a", func(c *Context) {
+		c.Text(http.StatusCreated, "hello")
+	})
+	e.GET("/health", func(c *Context) {})
+
+	req := httptest.NewRequest("GET", "/a", nil)
+	req.Header.Set(HeaderRequestID, "abc")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "abc", w.Header().Get(HeaderRequestID))
+	line := out.String()
+	assert.Contains(t, line, `method=GET path="/a" status=201`)
+	assert.Contains(t, line, "bytes=5 ip=192.0.2.1 request_id=abc\n")
+
+	out.Reset()
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	assert.Equal(t, 32, len(w.Header().Get(HeaderRequestID)))
+	assert.Empty(t, out.String())
+	assert.False(t, strings.Contains(out.String(), "health"))
+}
+
+func TestRequestIDValidation(t *testing.T) {
+	var out bytes.Buffer
+	e := New()
+	e.Use(RequestID(), LoggerWithConfig(LoggerConfig{Output: &out}))
+	e.GET("/a", func(c *Context) {})
+	for _, id := range []string{
+		"abc status=500",
+		"a\nb",
+		`"quoted"`,
+		strings.Repeat("a", 65),
+	} {
+		out.Reset()
+		req := httptest.NewRequest("GET", "/a", nil)
+		req.Header.Set(HeaderRequestID, id)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		got := w.Header().Get(HeaderRequestID)
+		assert.Equal(t, 32, len(got), id)
+		assert.Contains(t, out.String(), " request_id="+got+"\n")
+		assert.NotContains(t, out.String(), id)
+	}
+	for _, id := range []string{"0f8fad5b-d9cb-469f-a165-70867728950e", "svc:1.2_x", strings.Repeat("a", 64)} {
+		req := httptest.NewRequest("GET", "/a", nil)
+		req.Header.Set(HeaderRequestID, id)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		assert.Equal(t, id, w.Header().Get(HeaderRequestID))
+	}
+}
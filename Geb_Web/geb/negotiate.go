@@ -0,0 +1,132 @@
+package geb
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RenderFactory 根据数据创建一个Render，用于内容协商
+type RenderFactory func(data interface{}) Render
+
+type renderRegistry struct {
+	mu        sync.RWMutex
+	factories map[string]RenderFactory
+	// 注册顺序，协商时作为服务端的偏好
+	order []string
+}
+
+func newRenderRegistry() *renderRegistry {
+	r := &renderRegistry{factories: make(map[string]RenderFactory)}
+	r.register("application/json", func(data interface{}) Render { return JSONRender{data} })
+	r.register("application/xml", func(data interface{}) Render { return XMLRender{data} })
+	r.register("text/xml", func(data interface{}) Render { return XMLRender{data} })
+	r.register("application/yaml", func(data interface{}) Render { return YAMLRender{data} })
+	r.register("application/x-yaml", func(data interface{}) Render { return YAMLRender{data} })
+	r.register("application/x-protobuf", func(data interface{}) Render { return ProtoBuf{data} })
+	return r
+}
+
+func (r *renderRegistry) register(contentType string, f RenderFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.factories[contentType]; !ok {
+		r.order = append(r.order, contentType)
+	}
+	r.factories[contentType] = f
+}
+
+func (r *renderRegistry) get(contentType string) (RenderFactory, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	f, ok := r.factories[contentType]
+	return f, ok
+}
+
+func (r *renderRegistry) types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.order...)
+}
+
+// RegisterRender 为contentType注册一种可协商的格式，已存在时覆盖
+func (e *Engine) RegisterRender(contentType string, f RenderFactory) {
+	e.renders.register(contentType, f)
+}
+
+// Negotiate 根据Accept请求头从offered中选择格式输出data，offered为空时
+// 在全部已注册的格式中选择；q值相同时按offered的顺序优先，
+// 没有Accept请求头时使用第一个格式，没有可接受的格式时返回406
+func (c *Context) Negotiate(status int, data interface{}, offered ...string) error {
+	if len(offered) == 0 {
+		offered = c.engine.renders.types()
+	}
+	ct := NegotiateFormat(c.Req.Header.Get("Accept"), offered...)
+	if ct == "" {
+		c.AbortWithStatus(http.StatusNotAcceptable)
+		return nil
+	}
+	f, ok := c.engine.renders.get(ct)
+	if !ok {
+		return NewHTTPError(http.StatusInternalServerError, "未注册的格式"+ct)
+	}
+	return c.Render(status, f(data))
+}
+
+type acceptRange struct {
+	typ string
+	q   float64
+}
+
+// NegotiateFormat 返回offered中最符合accept的类型，没有时返回空字符串
+func NegotiateFormat(accept string, offered ...string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offered[0]
+	}
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		typ, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{typ, q})
+	}
+	best, bestQ := "", 0.0
+	for _, o := range offered {
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			if s := matchMedia(r.typ, o); s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if specificity >= 0 && q > bestQ {
+			best, bestQ = o, q
+		}
+	}
+	return best
+}
+
+// 返回匹配的精确程度，*/*为0，type/*为1，完全相同为2，不匹配为-1
+func matchMedia(pattern, typ string) int {
+	if pattern == typ {
+		return 2
+	}
+	if pattern == "*/*" {
+		return 0
+	}
+	if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(typ, pattern[:len(pattern)-1]) {
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,64 @@
+package geb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	offered := []string{"application/json", "application/xml", "application/yaml"}
+	assert.Equal(t, "application/json", NegotiateFormat("", offered...))
+	assert.Equal(t, "application/xml", NegotiateFormat("application/xml", offered...))
+	assert.Equal(t, "application/json", NegotiateFormat("*/*", offered...))
+	assert.Equal(t, "application/yaml", NegotiateFormat("application/json;q=0.5, application/yaml", offered...))
+	assert.Equal(t, "application/xml", NegotiateFormat("text/html, application/*;q=0.8, application/json;q=0.1", "application/json", "application/xml"))
+	assert.Equal(t, "", NegotiateFormat("text/html", offered...))
+	assert.Equal(t, "", NegotiateFormat("application/json;q=0", offered...))
+}
+
+type csvRender struct{ rows [][]string }
+
+func (r csvRender) ContentType() string { return "text/csv" }
+
+func (r csvRender) Render(w io.Writer) error {
+	for _, row := range r.rows {
+		for i, col := range row {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			io.WriteString(w, col)
+		}
+		io.WriteString(w, "\n")
+	}
+	return nil
+}
+
+func TestNegotiate(t *testing.T) {
+	e := New()
+	e.RegisterRender("text/csv", func(data interface{}) Render {
+		return csvRender{data.([][]string)}
+	})
+	e.GET("/p", func(c *Context) {
+		c.Negotiate(http.StatusOK, point{1, 2}, "application/json", "application/xml")
+	})
+	e.GET("/rows", func(c *Context) {
+		c.Negotiate(http.StatusOK, [][]string{{"a", "b"}}, "application/json", "text/csv")
+	})
+	req := httptest.NewRequest("GET", "/p", nil)
+	req.Header.Set("Accept", "application/xml")
+	w := serve(e, req)
+	assert.Equal(t, "<point><x>1</x><y>2</y></point>", w.Body.String())
+
+	req = httptest.NewRequest("GET", "/p", nil)
+	req.Header.Set("Accept", "text/csv")
+	assert.Equal(t, http.StatusNotAcceptable, serve(e, req).Code)
+
+	req = httptest.NewRequest("GET", "/rows", nil)
+	req.Header.Set("Accept", "text/csv")
+	w = serve(e, req)
+	assert.Equal(t, "a,b\n", w.Body.String())
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+}
@@ -0,0 +1,401 @@
+package geb
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balance 后端选择策略
+type Balance int
+
+const (
+	// RoundRobin 依次轮询
+	RoundRobin Balance = iota
+	// LeastConn 选择正在处理的请求最少的后端
+	LeastConn
+	// ConsistentHash 按HashKey做一致性哈希，同一个键总是落到同一个后端
+	ConsistentHash
+)
+
+// ProxyConfig 反向代理配置
+type ProxyConfig struct {
+	// Targets 后端地址，如http://127.0.0.1:8080/api
+	Targets []string
+	Balance Balance
+	// HashKey ConsistentHash使用的键，默认为客户端IP
+	HashKey func(c *Context) string
+	// Retries 幂等且没有请求体的请求在连接失败或后端返回502、503、504时，
+	// 换一个后端重试的次数，默认为后端数-1，小于0时不重试
+	Retries int
+	// MaxFails 连续失败多少次后暂时摘除后端，默认3
+	MaxFails int
+	// FailTimeout 摘除的时长，到期后重新参与选择，默认10秒
+	FailTimeout time.Duration
+	// PreserveHost 为true时保留请求的Host，否则改为后端的Host
+	PreserveHost bool
+	// StripPrefix 转发前从路径中去掉的前缀
+	StripPrefix string
+	// Transport 默认为http.DefaultTransport
+	Transport http.RoundTripper
+	// Director 在转发前修改请求
+	Director func(req *http.Request)
+	// ModifyResponse 在写出前修改响应，返回错误时按502处理
+	ModifyResponse func(resp *http.Response) error
+}
+
+// 虚拟节点数
+const proxyReplicas = 100
+
+type proxyTarget struct {
+	index int
+	url   *url.URL
+	// 正在处理的请求数
+	active int64
+
+	mu        sync.Mutex
+	fails     int
+	downUntil time.Time
+}
+
+type proxy struct {
+	conf    ProxyConfig
+	targets []*proxyTarget
+	// 一致性哈希环，按hash排序
+	ring []ringNode
+	next uint64
+}
+
+type ringNode struct {
+	hash   uint32
+	target *proxyTarget
+}
+
+// Proxy 将请求轮询转发到targets
+func Proxy(targets ...string) HandlerFunc {
+	return ProxyWithConfig(ProxyConfig{Targets: targets})
+}
+
+// ProxyWithConfig 返回反向代理处理函数，请求与响应体均以流的方式转发，
+// 全部后端都不可用时记录502错误；后端地址非法时panic
+//
+// 转发时会去掉逐跳首部，并设置X-Forwarded-For、X-Forwarded-Host与X-Forwarded-Proto；
+// 不支持转发WebSocket等Upgrade请求
+func ProxyWithConfig(conf ProxyConfig) HandlerFunc {
+	if len(conf.Targets) == 0 {
+		panic("geb: proxy requires at least one target")
+	}
+	if conf.Retries == 0 {
+		conf.Retries = len(conf.Targets) - 1
+	}
+	if conf.MaxFails <= 0 {
+		conf.MaxFails = 3
+	}
+	if conf.FailTimeout <= 0 {
+		conf.FailTimeout = 10 * time.Second
+	}
+	if conf.Transport == nil {
+		conf.Transport = http.DefaultTransport
+	}
+	if conf.HashKey == nil {
+		conf.HashKey = func(c *Context) string { return c.ClientIP() }
+	}
+	p := &proxy{conf: conf}
+	for i, t := range conf.Targets {
+		u, err := url.Parse(t)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			panic(fmt.Sprintf("后端地址非法：%s", t))
+		}
+		pt := &proxyTarget{index: i, url: u}
+		p.targets = append(p.targets, pt)
+		for r := 0; r < proxyReplicas; r++ {
+			h := ringHash(strconv.Itoa(r) + "-" + t)
+			p.ring = append(p.ring, ringNode{hash: h, target: pt})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool { return p.ring[i].hash < p.ring[j].hash })
+	return p.serve
+}
+
+// fnv-1a加上murmur3的混合步骤，使相近的键也能均匀分布在环上
+func ringHash(key string) uint32 {
+	f := fnv.New32a()
+	f.Write([]byte(key))
+	h := f.Sum32()
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+func (t *proxyTarget) alive(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !now.Before(t.downUntil)
+}
+
+func (t *proxyTarget) fail(maxFails int, timeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fails++
+	if t.fails >= maxFails {
+		t.fails = 0
+		t.downUntil = time.Now().Add(timeout)
+	}
+}
+
+func (t *proxyTarget) ok() {
+	t.mu.Lock()
+	t.fails = 0
+	t.mu.Unlock()
+}
+
+// 从没有尝试过的后端中选择一个，优先选择未被摘除的；
+// 全部被摘除时仍然尝试，避免后端恢复后无请求可以到达
+func (p *proxy) pick(c *Context, tried []bool) *proxyTarget {
+	now := time.Now()
+	var candidates []*proxyTarget
+	for _, t := range p.targets {
+		if !tried[t.index] && t.alive(now) {
+			candidates = append(candidates, t)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, t := range p.targets {
+			if !tried[t.index] {
+				candidates = append(candidates, t)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	ok := make([]bool, len(p.targets))
+	for _, t := range candidates {
+		ok[t.index] = true
+	}
+	switch p.conf.Balance {
+	case LeastConn:
+		best := candidates[0]
+		for _, t := range candidates[1:] {
+			if atomic.LoadInt64(&t.active) < atomic.LoadInt64(&best.active) {
+				best = t
+			}
+		}
+		return best
+	case ConsistentHash:
+		h := ringHash(p.conf.HashKey(c))
+		i := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+		for j := 0; j < len(p.ring); j++ {
+			if t := p.ring[(i+j)%len(p.ring)].target; ok[t.index] {
+				return t
+			}
+		}
+	}
+	n := atomic.AddUint64(&p.next, 1) - 1
+	for j := 0; j < len(p.targets); j++ {
+		if t := p.targets[(int(n%uint64(len(p.targets)))+j)%len(p.targets)]; ok[t.index] {
+			return t
+		}
+	}
+	return nil
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isRetryStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+func (p *proxy) serve(c *Context) {
+	attempts := 1
+	if isIdempotent(c.Req.Method) && c.Req.ContentLength == 0 && p.conf.Retries > 0 {
+		attempts += p.conf.Retries
+	}
+	tried := make([]bool, len(p.targets))
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		t := p.pick(c, tried)
+		if t == nil {
+			break
+		}
+		tried[t.index] = true
+		atomic.AddInt64(&t.active, 1)
+		resp, err := p.conf.Transport.RoundTrip(p.outRequest(c, t))
+		if err != nil {
+			atomic.AddInt64(&t.active, -1)
+			lastErr = err
+			if c.Req.Context().Err() != nil {
+				// 客户端已断开，不算作后端的失败
+				break
+			}
+			t.fail(p.conf.MaxFails, p.conf.FailTimeout)
+			continue
+		}
+		if isRetryStatus(resp.StatusCode) {
+			t.fail(p.conf.MaxFails, p.conf.FailTimeout)
+			if i < attempts-1 {
+				resp.Body.Close()
+				atomic.AddInt64(&t.active, -1)
+				lastErr = fmt.Errorf("后端返回%d", resp.StatusCode)
+				continue
+			}
+		} else {
+			t.ok()
+		}
+		p.writeResponse(c, resp)
+		atomic.AddInt64(&t.active, -1)
+		return
+	}
+	c.Error(&HTTPError{Code: http.StatusBadGateway, Message: "后端不可用", Err: lastErr})
+	c.Abort()
+}
+
+func (p *proxy) outRequest(c *Context, t *proxyTarget) *http.Request {
+	req := c.Req
+	out := req.Clone(req.Context())
+	if req.ContentLength == 0 {
+		out.Body = nil
+	}
+	out.RequestURI = ""
+	out.Close = false
+	path := req.URL.Path
+	if p.conf.StripPrefix != "" && strings.HasPrefix(path, p.conf.StripPrefix) {
+		path = path[len(p.conf.StripPrefix):]
+	}
+	out.URL.Scheme = t.url.Scheme
+	out.URL.Host = t.url.Host
+	out.URL.Path = singleJoiningSlash(t.url.Path, path)
+	out.URL.RawPath = ""
+	if t.url.RawQuery != "" && out.URL.RawQuery != "" {
+		out.URL.RawQuery = t.url.RawQuery + "&" + out.URL.RawQuery
+	} else if t.url.RawQuery != "" {
+		out.URL.RawQuery = t.url.RawQuery
+	}
+	if !p.conf.PreserveHost {
+		out.Host = t.url.Host
+	}
+
+	removeHopHeaders(out.Header)
+	ip := clientIP(req)
+	if prior := req.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+		ip = strings.Join(prior, ", ") + ", " + ip
+	}
+	out.Header.Set("X-Forwarded-For", ip)
+	out.Header.Set("X-Forwarded-Host", req.Host)
+	if req.TLS != nil {
+		out.Header.Set("X-Forwarded-Proto", "https")
+	} else {
+		out.Header.Set("X-Forwarded-Proto", "http")
+	}
+	if _, ok := out.Header["User-Agent"]; !ok {
+		// 避免Transport填入默认的User-Agent
+		out.Header.Set("User-Agent", "")
+	}
+	if p.conf.Director != nil {
+		p.conf.Director(out)
+	}
+	return out
+}
+
+func (p *proxy) writeResponse(c *Context, resp *http.Response) {
+	defer resp.Body.Close()
+	removeHopHeaders(resp.Header)
+	if p.conf.ModifyResponse != nil {
+		if err := p.conf.ModifyResponse(resp); err != nil {
+			c.Error(&HTTPError{Code: http.StatusBadGateway, Message: "后端不可用", Err: err})
+			c.Abort()
+			return
+		}
+	}
+	h := c.Writer.Header()
+	for k, vv := range resp.Header {
+		for _, v := range vv {
+			h.Add(k, v)
+		}
+	}
+	c.Writer.WriteHeader(resp.StatusCode)
+
+	// 长度未知的响应（如SSE）每次读取后立即刷新
+	streaming := resp.ContentLength == -1
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := c.Writer.Write(buf[:n]); werr != nil {
+				c.Error(werr)
+				return
+			}
+			if streaming {
+				c.Writer.Flush()
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	for k, vv := range resp.Trailer {
+		for _, v := range vv {
+			h.Add(http.TrailerPrefix+k, v)
+		}
+	}
+}
+
+// 逐跳首部，只对单个连接有效，不应转发
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			if name = textproto.TrimString(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash && b != "":
+		return a + "/" + b
+	}
+	return a + b
+}
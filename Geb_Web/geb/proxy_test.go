@@ -0,0 +1,207 @@
+package geb
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 返回名为name的后端，响应体为name
+func backend(t *testing.T, name string, hits *int64) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits != nil {
+			atomic.AddInt64(hits, 1)
+		}
+		io.WriteString(w, name)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestProxyHeaders(t *testing.T) {
+	var got *http.Request
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Header().Set("Connection", "X-Internal")
+		w.Header().Set("X-Internal", "1")
+		w.Header().Set("X-Backend", "b")
+		w.WriteHeader(http.StatusCreated)
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer s.Close()
+
+	e := New()
+	assert.Nil(t, e.Any("/api/*path", ProxyWithConfig(ProxyConfig{
+		Targets:     []string{s.URL + "/v1?k=1"},
+		StripPrefix: "/api",
+	})))
+	req := httptest.NewRequest("POST", "http://example.com/api/users?q=2", strings.NewReader("hello"))
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	req.Header.Set("Connection", "X-Hop")
+	req.Header.Set("X-Hop", "1")
+	w := serve(e, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "hello", w.Body.String())
+	assert.Equal(t, "b", w.Header().Get("X-Backend"))
+	assert.Empty(t, w.Header().Get("X-Internal"))
+	assert.Equal(t, "/v1/users", got.URL.Path)
+	assert.Equal(t, "k=1&q=2", got.URL.RawQuery)
+	assert.Equal(t, strings.TrimPrefix(s.URL, "http://"), got.Host)
+	assert.Equal(t, "10.0.0.1, 10.0.0.2", got.Header.Get("X-Forwarded-For"))
+	assert.Equal(t, "example.com", got.Header.Get("X-Forwarded-Host"))
+	assert.Equal(t, "http", got.Header.Get("X-Forwarded-Proto"))
+	assert.Empty(t, got.Header.Get("X-Hop"))
+	assert.Empty(t, got.Header.Get("User-Agent"))
+}
+
+func TestProxyRoundRobin(t *testing.T) {
+	a, b := backend(t, "a", nil), backend(t, "b", nil)
+	e := New()
+	assert.Nil(t, e.GET("/", Proxy(a.URL, b.URL)))
+	var bodies []string
+	for i := 0; i < 4; i++ {
+		bodies = append(bodies, serve(e, httptest.NewRequest("GET", "/", nil)).Body.String())
+	}
+	assert.Equal(t, []string{"a", "b", "a", "b"}, bodies)
+}
+
+func TestProxyLeastConn(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "slow")
+	}))
+	defer slow.Close()
+	fast := backend(t, "fast", nil)
+
+	e := New()
+	assert.Nil(t, e.GET("/", ProxyWithConfig(ProxyConfig{Targets: []string{slow.URL, fast.URL}, Balance: LeastConn})))
+	done := make(chan string)
+	go func() {
+		done <- serve(e, httptest.NewRequest("GET", "/", nil)).Body.String()
+	}()
+	<-started
+	// slow正在处理一个请求，后续请求都应转发到fast
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "fast", serve(e, httptest.NewRequest("GET", "/", nil)).Body.String())
+	}
+	close(release)
+	assert.Equal(t, "slow", <-done)
+}
+
+func TestProxyConsistentHash(t *testing.T) {
+	a, b, c := backend(t, "a", nil), backend(t, "b", nil), backend(t, "c", nil)
+	e := New()
+	assert.Nil(t, e.GET("/", ProxyWithConfig(ProxyConfig{
+		Targets: []string{a.URL, b.URL, c.URL},
+		Balance: ConsistentHash,
+		HashKey: func(c *Context) string { return c.Req.Header.Get("X-User") },
+	})))
+	seen := map[string]bool{}
+	for _, user := range []string{"u1", "u2", "u3", "u4", "u5", "u6", "u7", "u8"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User", user)
+		first := serve(e, req).Body.String()
+		seen[first] = true
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, first, serve(e, req).Body.String())
+		}
+	}
+	assert.True(t, len(seen) > 1)
+}
+
+func TestProxyRetryAndEject(t *testing.T) {
+	var downHits, upHits int64
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&downHits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := backend(t, "up", &upHits)
+
+	e := New()
+	assert.Nil(t, e.Any("/", ProxyWithConfig(ProxyConfig{
+		Targets:     []string{down.URL, up.URL},
+		MaxFails:    2,
+		FailTimeout: time.Minute,
+	})))
+	for i := 0; i < 6; i++ {
+		w := serve(e, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "up", w.Body.String())
+	}
+	// 连续失败2次后被摘除，不再收到请求
+	assert.Equal(t, int64(2), atomic.LoadInt64(&downHits))
+	assert.Equal(t, int64(6), atomic.LoadInt64(&upHits))
+
+	// POST不重试，摘除期间只会转发到up
+	w := serve(e, httptest.NewRequest("POST", "/", strings.NewReader("x")))
+	assert.Equal(t, "up", w.Body.String())
+}
+
+func TestProxyNoRetryForPost(t *testing.T) {
+	var hits int64
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	e := New()
+	assert.Nil(t, e.POST("/", Proxy(down.URL, down.URL)))
+	w := serve(e, httptest.NewRequest("POST", "/", strings.NewReader("x")))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&hits))
+}
+
+func TestProxyUnavailable(t *testing.T) {
+	s := httptest.NewServer(http.NotFoundHandler())
+	addr := s.URL
+	s.Close()
+	e := New()
+	assert.Nil(t, e.GET("/", Proxy(addr)))
+	w := serve(e, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	assert.Panics(t, func() { Proxy("not a url") })
+	assert.Panics(t, func() { Proxy() })
+}
+
+func TestProxyStreaming(t *testing.T) {
+	next := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: 1\n\n")
+		w.(http.Flusher).Flush()
+		<-next
+		io.WriteString(w, "data: 2\n\n")
+	}))
+	defer s.Close()
+
+	e := New()
+	assert.Nil(t, e.GET("/events", Proxy(s.URL)))
+	front := httptest.NewServer(e)
+	defer front.Close()
+	resp, err := http.Get(front.URL + "/events")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+	// 第二个事件发出前就能读到第一个事件
+	line, err := r.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "data: 1\n", line)
+	close(next)
+	rest, _ := io.ReadAll(r)
+	assert.Equal(t, "\ndata: 2\n\n", string(rest))
+}
@@ -0,0 +1,183 @@
+// Package ratelimit 限流中间件：按键的令牌桶限流与并发数限制
+//
+// 中间件可以注册在任意路由组上，每次调用New都会创建独立的令牌桶，例如：
+//
+//	api := e.Group("/api", ratelimit.New(ratelimit.Config{Rate: 10, Burst: 20}))
+//	login := e.Group("/login", ratelimit.New(ratelimit.Config{Rate: 1, Burst: 3}))
+package ratelimit
+
+import (
+	"geb"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KeyFunc 返回限流使用的键，返回空字符串时该请求不受限制
+type KeyFunc func(c *geb.Context) string
+
+// ByIP 按客户端IP限流
+func ByIP(c *geb.Context) string {
+	return c.ClientIP()
+}
+
+// ByHeader 按请求头的值限流，例如API Key
+func ByHeader(name string) KeyFunc {
+	return func(c *geb.Context) string {
+		return c.Req.Header.Get(name)
+	}
+}
+
+// Config 令牌桶配置
+type Config struct {
+	// Rate 每秒补充的令牌数
+	Rate float64
+	// Burst 桶的容量，即允许的突发请求数，默认等于Rate向上取整
+	Burst int
+	// KeyFunc 默认为ByIP
+	KeyFunc KeyFunc
+	// IdleTimeout 超过该时间没有请求的键会被清除，默认10分钟
+	IdleTimeout time.Duration
+}
+
+const defaultIdleTimeout = 10 * time.Minute
+
+// New 返回令牌桶限流中间件，超出限制时返回429并设置Retry-After
+func New(conf Config) geb.HandlerFunc {
+	if conf.KeyFunc == nil {
+		conf.KeyFunc = ByIP
+	}
+	l := NewLimiter(conf.Rate, conf.Burst, conf.IdleTimeout)
+	limit := strconv.Itoa(l.burst)
+	return func(c *geb.Context) {
+		key := conf.KeyFunc(c)
+		if key == "" {
+			return
+		}
+		ok, remaining, wait := l.Allow(key)
+		h := c.Writer.Header()
+		h.Set("X-RateLimit-Limit", limit)
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if !ok {
+			h.Set("Retry-After", retryAfter(wait))
+			c.AbortWithStatus(http.StatusTooManyRequests)
+		}
+	}
+}
+
+// Limiter 内存中的按键令牌桶，并发安全
+type Limiter struct {
+	rate  float64
+	burst int
+	idle  time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(rate float64, burst int, idle time.Duration) *Limiter {
+	if rate <= 0 {
+		panic("ratelimit: rate must be positive")
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	if idle <= 0 {
+		idle = defaultIdleTimeout
+	}
+	return &Limiter{rate: rate, burst: burst, idle: idle, buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Allow 尝试从key的桶中取出一个令牌，返回是否成功、剩余令牌数，
+// 以及失败时需要等待的时间
+func (l *Limiter) Allow(key string) (ok bool, remaining int, wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	b, has := l.buckets[key]
+	if !has {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, int(b.tokens), 0
+	}
+	return false, 0, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// Len 返回当前保存的键的数量
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// 每隔idle清除一次空闲的键，调用方需持有锁
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idle {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= l.idle {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// InFlightConfig 并发数限制配置
+type InFlightConfig struct {
+	// Max 同时处理的最大请求数
+	Max int
+	// StatusCode 超出限制时的状态码，默认503，也可以使用429
+	StatusCode int
+	// RetryAfter 写入Retry-After的建议等待时间，默认1秒
+	RetryAfter time.Duration
+}
+
+// MaxInFlight 限制同时处理的请求数，超出时立即拒绝而不排队
+func MaxInFlight(conf InFlightConfig) geb.HandlerFunc {
+	if conf.Max <= 0 {
+		panic("ratelimit: max in flight must be positive")
+	}
+	if conf.StatusCode == 0 {
+		conf.StatusCode = http.StatusServiceUnavailable
+	}
+	if conf.RetryAfter <= 0 {
+		conf.RetryAfter = time.Second
+	}
+	sem := make(chan struct{}, conf.Max)
+	return func(c *geb.Context) {
+		select {
+		case sem <- struct{}{}:
+		default:
+			c.SetHeader("Retry-After", retryAfter(conf.RetryAfter))
+			c.AbortWithStatus(conf.StatusCode)
+			return
+		}
+		defer func() { <-sem }()
+		c.Next()
+	}
+}
+
+// Retry-After以秒为单位，向上取整且至少为1
+func retryAfter(d time.Duration) string {
+	s := int(math.Ceil(d.Seconds()))
+	if s < 1 {
+		s = 1
+	}
+	return strconv.Itoa(s)
+}
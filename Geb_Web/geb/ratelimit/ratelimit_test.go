@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"geb"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(2, 3, time.Minute)
+	l.now = func() time.Time { return now }
+	for i := 2; i >= 0; i-- {
+		ok, remaining, _ := l.Allow("a")
+		assert.True(t, ok)
+		assert.Equal(t, i, remaining)
+	}
+	ok, _, wait := l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+	ok, _, _ = l.Allow("b")
+	assert.True(t, ok)
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _, _ = l.Allow("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, l.Len())
+
+	// 空闲的键被清除
+	now = now.Add(2 * time.Minute)
+	l.Allow("c")
+	assert.Equal(t, 1, l.Len())
+}
+
+func TestNew(t *testing.T) {
+	e := geb.New()
+	api := e.Group("/api", New(Config{Rate: 1, Burst: 2, KeyFunc: ByHeader("X-Key")}))
+	api.GET("/a", func(c *geb.Context) {
+		c.Text(http.StatusOK, "a")
+	})
+	e.GET("/free", func(c *geb.Context) {})
+	do := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Key", key)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusOK, do("/api/a", "k1").Code)
+	w := do("/api/a", "k1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	w = do("/api/a", "k1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, do("/api/a", "k2").Code)
+	assert.Equal(t, http.StatusOK, do("/api/a", "").Code)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, do("/free", "k1").Code)
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	e := geb.New()
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	e.Use(MaxInFlight(InFlightConfig{Max: 1, StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second}))
+	e.GET("/slow", func(c *geb.Context) {
+		close(entered)
+		<-release
+		c.Text(http.StatusOK, "ok")
+	})
+	e.GET("/fast", func(c *geb.Context) {
+		c.Text(http.StatusOK, "ok")
+	})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}()
+	<-entered
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3", w.Header().Get("Retry-After"))
+	close(release)
+	wg.Wait()
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,240 @@
+package geb
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Render 响应格式，实现该接口即可接入Context.Render与Context.Negotiate
+type Render interface {
+	// ContentType 返回写入Content-Type响应头的值
+	ContentType() string
+	// Render 将数据写入w，调用时状态码与响应头已经写出
+	Render(w io.Writer) error
+}
+
+// Render 以r的格式写出响应，r先渲染到缓冲区，出错时不会写出任何数据；
+// 流式的SSEvent不经过这里
+func (c *Context) Render(status int, r Render) error {
+	var buf bytes.Buffer
+	if err := r.Render(&buf); err != nil {
+		return err
+	}
+	c.SetContentType(r.ContentType())
+	c.SetStatus(status)
+	_, err := c.Write(buf.Bytes())
+	return err
+}
+
+// JSONRender 以JSON格式输出Data
+type JSONRender struct {
+	Data interface{}
+}
+
+func (r JSONRender) ContentType() string { return "application/json" }
+
+func (r JSONRender) Render(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r.Data)
+}
+
+// IndentedJSON 以缩进的JSON格式输出Data，便于阅读
+type IndentedJSON struct {
+	Data interface{}
+}
+
+func (r IndentedJSON) ContentType() string { return "application/json" }
+
+func (r IndentedJSON) Render(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(r.Data)
+}
+
+// JSONP 以callback(JSON)的形式输出Data，Callback为空时退化为JSON
+type JSONP struct {
+	Callback string
+	Data     interface{}
+}
+
+// 只允许合法的JavaScript标识符（可以用"."连接）作为回调名，防止注入
+var jsonpCallback = regexp.MustCompile(`^[A-Za-z_$][0-9A-Za-z_$]*(\.[A-Za-z_$][0-9A-Za-z_$]*)*$`)
+
+func (r JSONP) ContentType() string {
+	if r.Callback == "" {
+		return "application/json"
+	}
+	return "application/javascript"
+}
+
+func (r JSONP) Render(w io.Writer) error {
+	if r.Callback == "" {
+		return JSONRender{r.Data}.Render(w)
+	}
+	if !jsonpCallback.MatchString(r.Callback) {
+		return fmt.Errorf("非法的JSONP回调名%q", r.Callback)
+	}
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s(%s);", r.Callback, data)
+	return err
+}
+
+// XMLRender 以XML格式输出Data
+type XMLRender struct {
+	Data interface{}
+}
+
+func (r XMLRender) ContentType() string { return "application/xml; charset=utf-8" }
+
+func (r XMLRender) Render(w io.Writer) error {
+	return xml.NewEncoder(w).Encode(r.Data)
+}
+
+// YAMLRender 以YAML格式输出Data
+type YAMLRender struct {
+	Data interface{}
+}
+
+func (r YAMLRender) ContentType() string { return "application/yaml; charset=utf-8" }
+
+func (r YAMLRender) Render(w io.Writer) error {
+	data, err := yaml.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ProtoBuf 输出protobuf编码的Data，Data需要实现Marshal() ([]byte, error)，
+// gogo/protobuf生成的消息满足该接口；使用google.golang.org/protobuf时可以
+// 自行实现Render接口调用proto.Marshal
+type ProtoBuf struct {
+	Data interface{}
+}
+
+func (r ProtoBuf) ContentType() string { return "application/x-protobuf" }
+
+func (r ProtoBuf) Render(w io.Writer) error {
+	m, ok := r.Data.(interface{ Marshal() ([]byte, error) })
+	if !ok {
+		return fmt.Errorf("%T没有实现Marshal() ([]byte, error)", r.Data)
+	}
+	data, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (c *Context) IndentedJSON(status int, obj interface{}) error {
+	return c.Render(status, IndentedJSON{obj})
+}
+
+// JSONP 回调名取自查询参数callback
+func (c *Context) JSONP(status int, obj interface{}) error {
+	return c.Render(status, JSONP{Callback: c.Req.URL.Query().Get("callback"), Data: obj})
+}
+
+func (c *Context) XML(status int, obj interface{}) error {
+	return c.Render(status, XMLRender{obj})
+}
+
+func (c *Context) YAML(status int, obj interface{}) error {
+	return c.Render(status, YAMLRender{obj})
+}
+
+func (c *Context) ProtoBuf(status int, obj interface{}) error {
+	return c.Render(status, ProtoBuf{obj})
+}
+
+// SSEvent Server-Sent Events中的一个事件
+type SSEvent struct {
+	Event string
+	ID    string
+	// Retry 客户端重连间隔的毫秒数，0表示不设置
+	Retry int
+	// Data 字符串原样输出，其它类型按JSON编码
+	Data interface{}
+}
+
+func (r SSEvent) ContentType() string { return "text/event-stream" }
+
+func (r SSEvent) Render(w io.Writer) error {
+	var b strings.Builder
+	if r.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", strings.ReplaceAll(r.ID, "\n", ""))
+	}
+	if r.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", strings.ReplaceAll(r.Event, "\n", ""))
+	}
+	if r.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", r.Retry)
+	}
+	var data string
+	switch d := r.Data.(type) {
+	case string:
+		data = d
+	case []byte:
+		data = string(d)
+	default:
+		j, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		data = string(j)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// SSEvent 写出一个事件并立即flush，第一次调用时写出事件流的响应头
+func (c *Context) SSEvent(event string, data interface{}) error {
+	return c.writeEvent(SSEvent{Event: event, Data: data})
+}
+
+func (c *Context) writeEvent(e SSEvent) error {
+	if !c.Writer.Written() {
+		c.SetContentType(e.ContentType())
+		c.SetHeader("Cache-Control", "no-cache")
+		c.SetHeader("Connection", "keep-alive")
+		c.SetStatus(http.StatusOK)
+	}
+	if err := e.Render(c.Writer); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// Stream 反复调用step直到其返回false或客户端断开连接，每次调用后flush，
+// 客户端断开时返回true
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	done := c.Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(c.Writer)
+			c.Writer.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
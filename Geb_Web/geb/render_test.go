@@ -0,0 +1,74 @@
+package geb
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type point struct {
+	X int `json:"x" xml:"x" yaml:"x"`
+	Y int `json:"y" xml:"y" yaml:"y"`
+}
+
+type fakeProto struct{ b []byte }
+
+func (p fakeProto) Marshal() ([]byte, error) { return p.b, nil }
+
+func renderCtx(target string) (*Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	return newCtx(New(), w, httptest.NewRequest("GET", target, nil), nil, nil), w
+}
+
+func TestRenders(t *testing.T) {
+	p := point{1, 2}
+	c, w := renderCtx("/")
+	assert.Nil(t, c.XML(http.StatusOK, p))
+	assert.Equal(t, "<point><x>1</x><y>2</y></point>", w.Body.String())
+
+	c, w = renderCtx("/")
+	assert.Nil(t, c.YAML(http.StatusOK, p))
+	assert.Equal(t, "x: 1\n\"y\": 2\n", w.Body.String())
+	assert.Equal(t, "application/yaml; charset=utf-8", w.Header().Get("Content-Type"))
+
+	c, w = renderCtx("/")
+	assert.Nil(t, c.IndentedJSON(http.StatusOK, p))
+	assert.Equal(t, "{\n    \"x\": 1,\n    \"y\": 2\n}\n", w.Body.String())
+
+	c, w = renderCtx("/?callback=app.cb")
+	assert.Nil(t, c.JSONP(http.StatusOK, p))
+	assert.Equal(t, `app.cb({"x":1,"y":2});`, w.Body.String())
+	assert.Equal(t, "application/javascript", w.Header().Get("Content-Type"))
+
+	c, w = renderCtx("/?callback=alert(1)")
+	assert.Error(t, c.JSONP(http.StatusOK, p))
+	assert.False(t, c.Writer.Written())
+
+	c, w = renderCtx("/")
+	assert.Nil(t, c.ProtoBuf(http.StatusOK, fakeProto{[]byte{8, 1}}))
+	assert.Equal(t, []byte{8, 1}, w.Body.Bytes())
+	c, _ = renderCtx("/")
+	assert.Error(t, c.ProtoBuf(http.StatusOK, p))
+}
+
+func TestSSEvent(t *testing.T) {
+	c, w := renderCtx("/")
+	assert.Nil(t, c.SSEvent("message", "a\nb"))
+	assert.Nil(t, c.writeEvent(SSEvent{ID: "2", Retry: 100, Data: point{1, 2}}))
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "event: message\ndata: a\ndata: b\n\nid: 2\nretry: 100\ndata: {\"x\":1,\"y\":2}\n\n", w.Body.String())
+	assert.True(t, w.Flushed)
+
+	c, w = renderCtx("/")
+	i := 0
+	closed := c.Stream(func(out io.Writer) bool {
+		i++
+		fmt.Fprint(out, i)
+		return i < 3
+	})
+	assert.False(t, closed)
+	assert.Equal(t, "123", w.Body.String())
+}
@@ -2,16 +2,52 @@ package geb
 
 import (
 	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
 	"strings"
 )
 
 type Router interface {
 	AddHandler(method, patten string, handler HandlerFunc) error
 	Handlers(method, path string) []HandlerFunc
+	// Lookup 与Handlers相同，同时返回匹配过程中捕获的路径参数
+	Lookup(method, path string) ([]HandlerFunc, Params)
+	// Match 与Lookup相同，同时返回匹配到的模式
+	Match(method, path string) ([]HandlerFunc, Params, string)
 	AddMiddlewire(path string, handler ...HandlerFunc) error
+	// Middlewires 返回path沿途的中间件，用于没有匹配到处理函数的请求
+	Middlewires(path string) []HandlerFunc
+	// Routes 返回已注册的全部路由
+	Routes() []RouteInfo
 }
 
-// 支持3种模式：
+// Param 路径参数，由":name"或"*name"模式捕获
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params 按模式中出现的顺序保存的路径参数
+type Params []Param
+
+// Get 返回名为name的参数值，不存在时第二个返回值为false
+func (ps Params) Get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// ByName 返回名为name的参数值，不存在时返回空字符串
+func (ps Params) ByName(name string) string {
+	v, _ := ps.Get(name)
+	return v
+}
+
+// 支持5种模式：
 //
 // 1，精确匹配，/a/b/c
 //
@@ -23,33 +59,129 @@ type Router interface {
 //
 // /a/d/b匹配结果为真，/a/c和/a/c/d/b匹配结果为假
 //
+// 4，命名参数，/a/:id/b，":id"匹配任意一个路径，匹配到的值可以通过Context.Param("id")获取
+//
+// /a/1/b匹配结果为真，且id为"1"
+//
+// 5，命名通配，/a/*filepath，与"*"相同，但会以"x/y"的形式捕获匹配到的全部路径
+//
+// /a/x/y匹配结果为真，且filepath为"x/y"
+//
 // 当多个模式均匹配时，返回匹配度最高的结果，/a/*和/a/b/*，/a/b/c均可匹配/a/b/c，
-// 此时匹配结果为/a/b/c
+// 此时匹配结果为/a/b/c；同一位置的优先级为：精确匹配 > 命名参数 > "." > "*"
+//
+// 路由以按段压缩的基数树保存：只有一个子节点的连续静态段合并为一个节点，
+// 静态子节点按经过的路由数排序，较多时按首段建立索引。
+// 同一位置名称不同的命名参数或命名通配、以及重复注册的方法与模式会在注册时返回错误
 type defaultRouter struct {
-	root *node
+	root   *node
+	routes []RouteInfo
+	// 与routes一一对应，每条路由注册时附带的路由组中间件数量
+	groupMidwirs []int
 }
+
+// 可以为一条路由注册一串处理函数的Router，路由组用它把组的中间件放在处理函数之前；
+// 其他Router实现会收到把这一串合成后的单个处理函数
+type chainRouter interface {
+	addRoute(method, patten string, handlers []HandlerFunc) error
+}
+
+type nodeKind uint8
+
+const (
+	static nodeKind = iota
+	param
+	dot
+	catchAll
+)
+
+// 子节点多于该数量时建立索引
+const indexThreshold = 8
+
 type node struct {
-	part     string
-	midwirs  []HandlerFunc
-	handlers []HandlerFunc
+	// 静态节点可以包含多段，通配节点只有一段
+	parts []string
+	kind  nodeKind
+	// 静态子节点，按priority从大到小排列
 	children []*node
+	indices  map[string]*node
+	param    *node
+	dot      *node
+	catchAll *node
+	midwirs  []HandlerFunc
+	// 各方法的处理函数，最后一个之前的是注册时附带的路由组中间件
+	handlers [][]HandlerFunc
+	// 注册各方法的处理函数时使用的模式，下标与handlers相同；
+	// 不同的模式可能对应同一个节点，如/a/:id与/a/:name
+	pattens []string
+	// 经过该节点的路由数
+	priority int
+}
+
+// RouteInfo 一条已注册的路由
+type RouteInfo struct {
+	Method string
+	Patten string
+	// Handler 处理函数的名称
+	Handler string
+	// Middlewires 作用于该路由的中间件数量
+	Middlewires int
 }
 
 const (
-	GET  = 0
-	POST = 1
+	GET = iota
+	POST
+	PUT
+	DELETE
+	PATCH
+	HEAD
+	OPTIONS
+	CONNECT
+	TRACE
 )
 
+// 下标与上面的常量一一对应
+var methods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodDelete,
+	http.MethodPatch,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodConnect,
+	http.MethodTrace,
+}
+
 func methodToInt(method string) int {
-	m := -1
-	switch method {
-	case "GET":
-		m = GET
-	case "POST":
-		m = POST
+	for i, m := range methods {
+		if m == method {
+			return i
+		}
+	}
+	return -1
+}
+
+// 返回path在router中注册过的全部方法，HEAD会在GET存在时自动加入
+func allowedMethods(router Router, path string) []string {
+	var allowed []string
+	for _, m := range methods {
+		if len(router.Handlers(m, path)) > 0 {
+			allowed = append(allowed, m)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
 	}
-	return m
+	if len(router.Handlers(http.MethodHead, path)) == 0 && len(router.Handlers(http.MethodGet, path)) > 0 {
+		allowed = append(allowed, http.MethodHead)
+	}
+	if len(router.Handlers(http.MethodOptions, path)) == 0 {
+		allowed = append(allowed, http.MethodOptions)
+	}
+	return allowed
 }
+
 func isValidPatten(patten string) bool {
 	if len(patten) == 0 || patten[0] != '/' {
 		return false
@@ -60,9 +192,26 @@ func isValidPatten(patten string) bool {
 	star := strings.Index(patten, "*")
 	dot := strings.Index(patten, ".")
 	l := len(patten)
-	if (star != -1 && star != l-1) || dot == l-1 || (star >= 0 && dot >= 0) {
+	// "*"只能出现在最后一段的开头
+	if star != -1 && (star != strings.LastIndex(patten, "/")+1 || strings.Count(patten, "*") > 1) {
+		return false
+	}
+	if dot == l-1 || (star >= 0 && dot >= 0) {
 		return false
 	}
+	names := map[string]bool{}
+	for _, p := range strings.Split(patten, "/") {
+		if !isParam(p) && !isCatchAll(p) {
+			continue
+		}
+		name := p[1:]
+		if (isParam(p) && len(name) == 0) || names[name] {
+			return false
+		}
+		if len(name) > 0 {
+			names[name] = true
+		}
+	}
 	if dot != -1 {
 		dot = strings.Index(patten[dot+1:], ".")
 		if dot != -1 {
@@ -71,6 +220,14 @@ func isValidPatten(patten string) bool {
 	}
 	return true
 }
+func isParam(part string) bool {
+	return strings.HasPrefix(part, ":")
+}
+
+func isCatchAll(part string) bool {
+	return strings.HasPrefix(part, "*")
+}
+
 func splitP(p string) []string {
 	ps := strings.Split(p, "/")
 	l := len(ps)
@@ -84,6 +241,10 @@ func splitP(p string) []string {
 	return ps[:l]
 }
 func (router *defaultRouter) AddHandler(method, patten string, handler HandlerFunc) error {
+	return router.addRoute(method, patten, []HandlerFunc{handler})
+}
+
+func (router *defaultRouter) addRoute(method, patten string, handlers []HandlerFunc) error {
 	m := methodToInt(method)
 	if m < 0 {
 		return fmt.Errorf("无法识别的方法%s", method)
@@ -95,110 +256,261 @@ func (router *defaultRouter) AddHandler(method, patten string, handler HandlerFu
 	if len(ps) == 0 {
 		return fmt.Errorf("无法识别的模式%s", patten)
 	}
-	n := updateTree(router, ps)
+	n, err := router.find(ps[1:])
+	if err != nil {
+		return err
+	}
+	if n.has(m) {
+		return fmt.Errorf("%s %s重复注册", method, patten)
+	}
+	n = router.insert(ps[1:], true)
 	if m > len(n.handlers)-1 {
-		ha := make([]HandlerFunc, m+1)
-		for i, ff := range n.handlers {
-			ha[i] = ff
-		}
+		ha := make([][]HandlerFunc, m+1)
+		copy(ha, n.handlers)
 		n.handlers = ha
+		pa := make([]string, m+1)
+		copy(pa, n.pattens)
+		n.pattens = pa
 	}
-	n.handlers[m] = handler
+	n.handlers[m] = handlers
+	n.pattens[m] = patten
+	router.routes = append(router.routes, RouteInfo{Method: method, Patten: patten, Handler: funcName(handlers[len(handlers)-1])})
+	router.groupMidwirs = append(router.groupMidwirs, len(handlers)-1)
 	return nil
 }
-func updateTree(router *defaultRouter, ps []string) *node {
+
+func funcName(f HandlerFunc) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return ""
+}
+
+func wildcardKind(part string) nodeKind {
+	switch {
+	case isParam(part):
+		return param
+	case part == ".":
+		return dot
+	case isCatchAll(part):
+		return catchAll
+	}
+	return static
+}
+
+// 返回parts与prefix的公共前缀段数
+func commonParts(parts, prefix []string) int {
+	i := 0
+	for i < len(parts) && i < len(prefix) && parts[i] == prefix[i] {
+		i++
+	}
+	return i
+}
+
+func (n *node) has(m int) bool {
+	return n != nil && m < len(n.handlers) && len(n.handlers[m]) > 0
+}
+
+func (n *node) child(part string) *node {
+	if n.indices != nil {
+		return n.indices[part]
+	}
+	for _, c := range n.children {
+		if c.parts[0] == part {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *node) addChild(c *node) {
+	n.children = append(n.children, c)
+	if n.indices != nil {
+		n.indices[c.parts[0]] = c
+	} else if len(n.children) > indexThreshold {
+		n.indices = make(map[string]*node, len(n.children))
+		for _, cc := range n.children {
+			n.indices[cc.parts[0]] = cc
+		}
+	}
+}
+
+func (n *node) wildcard(kind nodeKind) **node {
+	switch kind {
+	case param:
+		return &n.param
+	case dot:
+		return &n.dot
+	}
+	return &n.catchAll
+}
+
+// 增加c的priority，并保持children有序
+func (n *node) incrPriority(c *node) {
+	c.priority++
+	i := 0
+	for n.children[i] != c {
+		i++
+	}
+	for ; i > 0 && n.children[i-1].priority < c.priority; i-- {
+		n.children[i-1], n.children[i] = n.children[i], n.children[i-1]
+	}
+}
+
+// 将静态节点在第i段处拆成两个节点，n保留前i段，原有的子节点与处理函数移到新节点上
+func (n *node) split(i int) {
+	c := *n
+	c.parts = n.parts[i:]
+	*n = node{parts: n.parts[:i], kind: static, priority: n.priority}
+	n.children = []*node{&c}
+}
+
+// 只读地查找parts对应的节点，不存在时返回nil；同一位置已有名称不同的通配时返回错误
+func (router *defaultRouter) find(parts []string) (*node, error) {
+	n := router.root
+	for n != nil && len(parts) > 0 {
+		p := parts[0]
+		if kind := wildcardKind(p); kind != static {
+			w := *n.wildcard(kind)
+			if w != nil && w.parts[0] != p {
+				return nil, fmt.Errorf("%s与已有的%s冲突", p, w.parts[0])
+			}
+			n, parts = w, parts[1:]
+			continue
+		}
+		c := n.child(p)
+		if c == nil || commonParts(parts, c.parts) < len(c.parts) {
+			return nil, nil
+		}
+		n, parts = c, parts[len(c.parts):]
+	}
+	return n, nil
+}
+
+// 插入parts对应的节点并返回，route为true时增加沿途节点的priority
+func (router *defaultRouter) insert(parts []string, route bool) *node {
 	if router.root == nil {
-		router.root = &node{part: "/"}
+		router.root = &node{}
 	}
 	n := router.root
-outer:
-	for _, p := range ps[1:] {
-		for _, nn := range n.children {
-			if nn.part == p {
-				n = nn
-				continue outer
+	for len(parts) > 0 {
+		p := parts[0]
+		if kind := wildcardKind(p); kind != static {
+			w := n.wildcard(kind)
+			if *w == nil {
+				*w = &node{parts: []string{p}, kind: kind}
+			}
+			n, parts = *w, parts[1:]
+			if route {
+				n.priority++
 			}
+			continue
 		}
-		n3 := node{part: p}
-		n.children = append(n.children, &n3)
-		n = &n3
+		c := n.child(p)
+		if c == nil {
+			k := 1
+			for k < len(parts) && wildcardKind(parts[k]) == static {
+				k++
+			}
+			c = &node{parts: append([]string(nil), parts[:k]...)}
+			n.addChild(c)
+		} else if i := commonParts(parts, c.parts); i < len(c.parts) {
+			c.split(i)
+		}
+		if route {
+			n.incrPriority(c)
+		}
+		n, parts = c, parts[len(c.parts):]
 	}
 	return n
 }
+
 func (router *defaultRouter) Handlers(method, path string) (handlers []HandlerFunc) {
+	handlers, _ = router.Lookup(method, path)
+	return handlers
+}
+
+func (router *defaultRouter) Lookup(method, path string) ([]HandlerFunc, Params) {
+	handlers, params, _ := router.Match(method, path)
+	return handlers, params
+}
+
+func (router *defaultRouter) Match(method, path string) ([]HandlerFunc, Params, string) {
 	if router.root == nil {
-		return nil
+		return nil, nil, ""
 	}
 	m := methodToInt(method)
 	if m < 0 {
-		return nil
+		return nil, nil, ""
 	}
 	ps := splitP(path)
 	if len(ps) == 0 {
-		return nil
+		return nil, nil, ""
 	}
-	handlers, has := doGetHandlers(router.root, ps, m)
-	if !has {
-		return nil
+	var params Params
+	handlers := append([]HandlerFunc(nil), router.root.midwirs...)
+	n := router.root.search(ps[1:], m, &params, &handlers)
+	if n == nil {
+		return nil, nil, ""
 	}
-	return handlers
+	return append(handlers, n.handlers[m]...), params, n.pattens[m]
 }
-func doGetHandlers(n *node, ps []string, m int) ([]HandlerFunc, bool) {
-	if n == nil || len(ps) == 0 {
-		return nil, false
+
+// 在n之后匹配parts，按精确匹配、命名参数、"."、"*"的顺序回溯；
+// 同时收集实际匹配的节点上的中间件，回溯时一并撤销
+func (n *node) search(parts []string, m int, params *Params, midwirs *[]HandlerFunc) *node {
+	if len(parts) == 0 {
+		if n.has(m) {
+			return n
+		}
+		return nil
 	}
-	if n.part == "." {
-		for _, nn := range n.children {
-			p, hasHandler := doGetHandlers(nn, ps[1:], m)
-			if hasHandler {
-				return p, true
-			}
+	p := parts[0]
+	l := len(*midwirs)
+	if c := n.child(p); c != nil && commonParts(parts, c.parts) == len(c.parts) {
+		*midwirs = append(*midwirs, c.midwirs...)
+		if r := c.search(parts[len(c.parts):], m, params, midwirs); r != nil {
+			return r
 		}
-		return nil, false
+		*midwirs = (*midwirs)[:l]
 	}
-	if n.part == "*" {
-		if m < len(n.handlers) && n.handlers[m] != nil {
-			handlers := []HandlerFunc{n.handlers[m]}
-			return handlers, true
+	if n.param != nil {
+		pl := len(*params)
+		*params = append(*params, Param{Key: n.param.parts[0][1:], Value: p})
+		*midwirs = append(*midwirs, n.param.midwirs...)
+		if r := n.param.search(parts[1:], m, params, midwirs); r != nil {
+			return r
 		}
-		return nil, false
+		*params = (*params)[:pl]
+		*midwirs = (*midwirs)[:l]
 	}
-	if n.part == ps[0] {
-		var handlers []HandlerFunc
-		handlers = append(handlers, n.midwirs...)
-		if len(ps) == 1 {
-			if m >= len(n.handlers) || n.handlers[m] == nil {
-				return handlers, false
-			}
-			return append(handlers, n.handlers[m]), true
-		}
-
-		var h1, h2, h3 []HandlerFunc
-		var has1, has2, has3 bool
-		for _, nn := range n.children {
-			if nn.part == "*" {
-				h1, has1 = doGetHandlers(nn, ps[1:], m)
-			} else if nn.part == "." {
-				h2, has2 = doGetHandlers(nn, ps[1:], m)
-			} else if nn.part == ps[1] {
-				h3, has3 = doGetHandlers(nn, ps[1:], m)
-			}
+	if n.dot != nil {
+		if r := n.dot.search(parts[1:], m, params, midwirs); r != nil {
+			return r
 		}
+	}
+	if n.catchAll.has(m) {
+		if name := n.catchAll.parts[0][1:]; name != "" {
+			*params = append(*params, Param{Key: name, Value: strings.Join(parts, "/")})
+		}
+		n.staticMidwirs(parts, midwirs)
+		return n.catchAll
+	}
+	return nil
+}
 
-		if has3 {
-			return append(handlers, h3...), true
-		} else if has2 {
-			return append(handlers, h2...), true
-		} else if has1 {
-			handlers = append(handlers, h3...)
-			handlers = append(handlers, h1...)
-			return handlers, true
-		} else {
-			return h3, false
+// 由"*"匹配时，"*"之后沿请求路径能精确匹配的静态节点上的中间件同样执行
+func (n *node) staticMidwirs(parts []string, midwirs *[]HandlerFunc) {
+	for len(parts) > 0 {
+		c := n.child(parts[0])
+		if c == nil || commonParts(parts, c.parts) < len(c.parts) {
+			return
 		}
+		*midwirs = append(*midwirs, c.midwirs...)
+		n, parts = c, parts[len(c.parts):]
 	}
-	return nil, false
 }
+
 func (router *defaultRouter) AddMiddlewire(path string, handler ...HandlerFunc) error {
 	ps := splitP(path)
 	if len(path) > 0 && path[0] != '/' {
@@ -207,7 +519,60 @@ func (router *defaultRouter) AddMiddlewire(path string, handler ...HandlerFunc)
 	if len(ps) == 0 || strings.Index(path, ".") >= 0 || strings.Index(path, "*") >= 0 {
 		return fmt.Errorf("路径格式错误%s", path)
 	}
-	n := updateTree(router, ps)
+	if _, err := router.find(ps[1:]); err != nil {
+		return err
+	}
+	n := router.insert(ps[1:], false)
 	n.midwirs = append(n.midwirs, handler...)
 	return nil
 }
+
+// 沿path逐段向下查找，精确匹配优先于命名参数，遇到无法匹配的部分时停止
+func (router *defaultRouter) Middlewires(path string) []HandlerFunc {
+	ps := splitP(path)
+	if router.root == nil || len(ps) == 0 {
+		return nil
+	}
+	return router.midwirs(ps[1:], false)
+}
+
+// 收集parts沿途的中间件；patten为true时parts来自模式，命名参数只走命名参数节点，
+// 遇到"."或"*"时停止
+func (router *defaultRouter) midwirs(parts []string, patten bool) []HandlerFunc {
+	n := router.root
+	handlers := append([]HandlerFunc(nil), n.midwirs...)
+	for len(parts) > 0 {
+		p := parts[0]
+		kind := static
+		if patten {
+			kind = wildcardKind(p)
+		}
+		if kind == static {
+			if c := n.child(p); c != nil {
+				// 停在压缩节点的中间时，后续的段无法匹配，与逐段匹配时一样停止
+				if commonParts(parts, c.parts) < len(c.parts) {
+					break
+				}
+				n, parts = c, parts[len(c.parts):]
+				handlers = append(handlers, n.midwirs...)
+				continue
+			}
+		}
+		if n.param == nil || (kind != static && kind != param) {
+			break
+		}
+		n, parts = n.param, parts[1:]
+		handlers = append(handlers, n.midwirs...)
+	}
+	return handlers
+}
+
+// Routes 按注册顺序返回全部路由
+func (router *defaultRouter) Routes() []RouteInfo {
+	routes := make([]RouteInfo, len(router.routes))
+	for i, r := range router.routes {
+		r.Middlewires = len(router.midwirs(splitP(r.Patten)[1:], true)) + router.groupMidwirs[i]
+		routes[i] = r
+	}
+	return routes
+}
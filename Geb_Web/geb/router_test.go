@@ -1,7 +1,9 @@
 package geb
 
 import (
+	"github.com/stretchr/testify/assert"
 	"reflect"
+	"strconv"
 	"testing"
 )
 
@@ -91,8 +93,11 @@ func TestMethodToInt(t *testing.T) {
 	if methodToInt("POST") != 1 {
 		t.Error("POST should be 1")
 	}
-	if methodToInt("PATCH") != -1 {
-		t.Error("PATCH should be -1")
+	if methodToInt("PATCH") != PATCH {
+		t.Error("PATCH should be PATCH")
+	}
+	if methodToInt("get") != -1 {
+		t.Error("get should be -1")
 	}
 }
 
@@ -221,9 +226,11 @@ func TestRouter(t *testing.T) {
 	if !isFunEqual(h, q) {
 		t.Error("8")
 	}
-	// patch / \
+	// patch / f2
+	router.AddHandler("PATCH", "/", f2)
 	h = router.Handlers("PATCH", "/")
-	if len(h) != 0 {
+	q = []HandlerFunc{m1, f2}
+	if !isFunEqual(h, q) {
 		t.Error("9")
 	}
 
@@ -256,5 +263,265 @@ func countFunc(n *node) int {
 	for _, nn := range n.children {
 		c += countFunc(nn)
 	}
-	return c
+	return c + countFunc(n.param) + countFunc(n.dot) + countFunc(n.catchAll)
+}
+
+func TestIsValidPatternParams(t *testing.T) {
+	if !isValidPatten("/users/:id") {
+		t.Error("/users/:id should valid")
+	}
+	if !isValidPatten("/users/:id/books/:book") {
+		t.Error("/users/:id/books/:book should valid")
+	}
+	if !isValidPatten("/static/*filepath") {
+		t.Error("/static/*filepath should valid")
+	}
+	if !isValidPatten("/users/:id/*rest") {
+		t.Error("/users/:id/*rest should valid")
+	}
+	if isValidPatten("/users/:") {
+		t.Error("/users/: should invalid")
+	}
+	if isValidPatten("/users/:id/:id") {
+		t.Error("/users/:id/:id should invalid")
+	}
+	if isValidPatten("/static/*filepath/a") {
+		t.Error("/static/*filepath/a should invalid")
+	}
+	if isValidPatten("/static/a*") {
+		t.Error("/static/a* should invalid")
+	}
+}
+
+func TestRouterParams(t *testing.T) {
+	router := defaultRouter{}
+	f1 := func(c *Context) {}
+	f2 := func(c *Context) {}
+	f3 := func(c *Context) {}
+	f4 := func(c *Context) {}
+	m1 := func(c *Context) {}
+	assert.Nil(t, router.AddHandler("GET", "/users/:id", f1))
+	assert.Nil(t, router.AddHandler("GET", "/users/me", f2))
+	assert.Nil(t, router.AddHandler("GET", "/users/:id/books/:book", f3))
+	assert.Nil(t, router.AddHandler("GET", "/static/*filepath", f4))
+	assert.Nil(t, router.AddMiddlewire("/users/:id", m1))
+	assert.Error(t, router.AddHandler("GET", "/users/:name/x", f1))
+	assert.Error(t, router.AddHandler("GET", "/static/*other", f1))
+
+	h, ps := router.Lookup("GET", "/users/42")
+	assert.True(t, isFunEqual(h, []HandlerFunc{m1, f1}))
+	assert.Equal(t, Params{{Key: "id", Value: "42"}}, ps)
+
+	// 精确匹配优先
+	h, ps = router.Lookup("GET", "/users/me")
+	assert.True(t, isFunEqual(h, []HandlerFunc{f2}))
+	assert.Empty(t, ps)
+
+	h, ps = router.Lookup("GET", "/users/42/books/go")
+	assert.True(t, isFunEqual(h, []HandlerFunc{m1, f3}))
+	assert.Equal(t, "42", ps.ByName("id"))
+	assert.Equal(t, "go", ps.ByName("book"))
+
+	h, ps = router.Lookup("GET", "/static/css/main.css")
+	assert.True(t, isFunEqual(h, []HandlerFunc{f4}))
+	assert.Equal(t, "css/main.css", ps.ByName("filepath"))
+
+	h, ps = router.Lookup("GET", "/users/42/books")
+	assert.Empty(t, h)
+	assert.Empty(t, ps)
+}
+
+func TestRouterMiddlewires(t *testing.T) {
+	router := defaultRouter{}
+	assert.Empty(t, router.Middlewires("/a"))
+	m1 := func(c *Context) {}
+	m2 := func(c *Context) {}
+	m3 := func(c *Context) {}
+	router.AddMiddlewire("/", m1)
+	router.AddMiddlewire("/a", m2)
+	router.AddMiddlewire("/a/:id", m3)
+	assert.True(t, isFunEqual(router.Middlewires("/"), []HandlerFunc{m1}))
+	assert.True(t, isFunEqual(router.Middlewires("/b/c"), []HandlerFunc{m1}))
+	assert.True(t, isFunEqual(router.Middlewires("/a/1/x"), []HandlerFunc{m1, m2, m3}))
+}
+
+// 构造一个较大的路由表：5个版本 x 40种资源 x 5种子路由，共1000条
+func benchRouter(b *testing.B) Router {
+	router := &defaultRouter{}
+	h := func(c *Context) {}
+	for v := 1; v <= 5; v++ {
+		for r := 0; r < 40; r++ {
+			base := "/api/v" + string(rune('0'+v)) + "/res" + strconv.Itoa(r)
+			for _, p := range []string{"", "/:id", "/:id/items", "/:id/items/:item", "/search/*query"} {
+				if err := router.AddHandler("GET", base+p, h); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+	router.AddMiddlewire("/api", h)
+	return router
+}
+
+func BenchmarkRouterStatic(b *testing.B) {
+	router := benchRouter(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.Lookup("GET", "/api/v5/res39")
+	}
+}
+
+func BenchmarkRouterParam(b *testing.B) {
+	router := benchRouter(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.Lookup("GET", "/api/v5/res39/42/items/7")
+	}
+}
+
+func BenchmarkRouterCatchAll(b *testing.B) {
+	router := benchRouter(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.Lookup("GET", "/api/v3/res20/search/a/b/c")
+	}
+}
+
+func BenchmarkRouterNotFound(b *testing.B) {
+	router := benchRouter(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.Lookup("GET", "/api/v9/none/1")
+	}
+}
+
+func TestRouterConflicts(t *testing.T) {
+	router := defaultRouter{}
+	f := func(c *Context) {}
+	assert.Nil(t, router.AddHandler("GET", "/a/:id", f))
+	assert.Nil(t, router.AddHandler("POST", "/a/:id", f))
+	assert.Error(t, router.AddHandler("GET", "/a/:id/", f))
+	assert.Error(t, router.AddHandler("GET", "/a/:name", f))
+	assert.Error(t, router.AddMiddlewire("/a/:name", f))
+	assert.Nil(t, router.AddHandler("GET", "/a/./b", f))
+	assert.Nil(t, router.AddHandler("GET", "/a/*", f))
+	assert.Error(t, router.AddHandler("GET", "/a/*rest", f))
+	// 冲突或重复的模式不会改动树
+	a := router.root.child("a")
+	assert.Equal(t, "*", a.catchAll.parts[0])
+	assert.Equal(t, 4, a.priority)
+}
+
+func TestRouterCompress(t *testing.T) {
+	router := defaultRouter{}
+	f := func(c *Context) {}
+	g := func(c *Context) {}
+	assert.Nil(t, router.AddHandler("GET", "/a/b/c/d", f))
+	a := router.root.child("a")
+	assert.Equal(t, []string{"a", "b", "c", "d"}, a.parts)
+	assert.Empty(t, router.Handlers("GET", "/a/b"))
+
+	// 在中间插入时拆分节点
+	assert.Nil(t, router.AddHandler("GET", "/a/b/x", g))
+	assert.Equal(t, []string{"a", "b"}, a.parts)
+	assert.Len(t, a.children, 2)
+	assert.True(t, isFunEqual(router.Handlers("GET", "/a/b/c/d"), []HandlerFunc{f}))
+	assert.True(t, isFunEqual(router.Handlers("GET", "/a/b/x"), []HandlerFunc{g}))
+
+	// 中间件挂在压缩节点中间时同样拆分
+	m := func(c *Context) {}
+	assert.Nil(t, router.AddMiddlewire("/a/b/c", m))
+	assert.True(t, isFunEqual(router.Handlers("GET", "/a/b/c/d"), []HandlerFunc{m, f}))
+	assert.True(t, isFunEqual(router.Handlers("GET", "/a/b/x"), []HandlerFunc{g}))
+	assert.Empty(t, router.Handlers("GET", "/a/b/c"))
+}
+
+func TestRouterPriority(t *testing.T) {
+	router := defaultRouter{}
+	f := func(c *Context) {}
+	assert.Nil(t, router.AddHandler("GET", "/a", f))
+	assert.Nil(t, router.AddHandler("GET", "/b", f))
+	assert.Nil(t, router.AddHandler("GET", "/b/1", f))
+	assert.Nil(t, router.AddHandler("GET", "/b/2", f))
+	assert.Equal(t, "b", router.root.children[0].parts[0])
+	assert.Equal(t, 3, router.root.children[0].priority)
+
+	// 子节点较多时按首段建立索引
+	for i := 0; i < indexThreshold+1; i++ {
+		assert.Nil(t, router.AddHandler("GET", "/c/"+strconv.Itoa(i), f))
+	}
+	c := router.root.child("c")
+	assert.NotNil(t, c.indices)
+	for i := 0; i < indexThreshold+1; i++ {
+		assert.True(t, isFunEqual(router.Handlers("GET", "/c/"+strconv.Itoa(i)), []HandlerFunc{f}))
+	}
+}
+
+func routeHandler(c *Context) {}
+
+func TestRouterRoutes(t *testing.T) {
+	router := defaultRouter{}
+	m := func(c *Context) {}
+	assert.Nil(t, router.AddHandler("GET", "/users/:id", routeHandler))
+	assert.Nil(t, router.AddHandler("POST", "/users", routeHandler))
+	assert.Nil(t, router.AddHandler("GET", "/files/*path", routeHandler))
+	assert.Nil(t, router.AddMiddlewire("/", m))
+	assert.Nil(t, router.AddMiddlewire("/users/:id", m, m))
+
+	routes := router.Routes()
+	assert.Equal(t, []RouteInfo{
+		{Method: "GET", Patten: "/users/:id", Handler: "geb.routeHandler", Middlewires: 3},
+		{Method: "POST", Patten: "/users", Handler: "geb.routeHandler", Middlewires: 1},
+		{Method: "GET", Patten: "/files/*path", Handler: "geb.routeHandler", Middlewires: 1},
+	}, routes)
+}
+
+func TestRouterMatch(t *testing.T) {
+	router := defaultRouter{}
+	f := func(c *Context) {}
+	assert.Nil(t, router.AddHandler("GET", "/users/:id/", f))
+	assert.Nil(t, router.AddHandler("POST", "/users/:id", f))
+	assert.Nil(t, router.AddHandler("GET", "/files/*path", f))
+	_, _, patten := router.Match("GET", "/users/1")
+	assert.Equal(t, "/users/:id/", patten)
+	// 每个方法使用自己注册时的模式
+	_, _, patten = router.Match("POST", "/users/1")
+	assert.Equal(t, "/users/:id", patten)
+	_, _, patten = router.Match("GET", "/files/a/b")
+	assert.Equal(t, "/files/*path", patten)
+	_, _, patten = router.Match("GET", "/none")
+	assert.Empty(t, patten)
+}
+
+// 静态节点与带中间件的命名参数节点相邻时，中间件按实际匹配的分支收集
+func TestRouterMatchMiddlewire(t *testing.T) {
+	router := defaultRouter{}
+	var called []string
+	mw := func(name string) HandlerFunc {
+		return func(c *Context) { called = append(called, name) }
+	}
+	assert.Nil(t, router.AddMiddlewire("/", mw("root")))
+	assert.Nil(t, router.AddMiddlewire("/a/:id", mw("id")))
+	assert.Nil(t, router.AddMiddlewire("/a/b", mw("b")))
+	assert.Nil(t, router.AddHandler("GET", "/a/:id/x", mw("x")))
+	assert.Nil(t, router.AddHandler("GET", "/a/b/y", mw("y")))
+
+	run := func(path string) []string {
+		called = nil
+		handlers, _ := router.Lookup("GET", path)
+		for _, h := range handlers {
+			h(nil)
+		}
+		return called
+	}
+	handlers, params := router.Lookup("GET", "/a/b/x")
+	assert.Len(t, handlers, 3)
+	assert.Equal(t, "b", params.ByName("id"))
+	assert.Equal(t, []string{"root", "id", "x"}, run("/a/b/x"))
+	assert.Equal(t, []string{"root", "b", "y"}, run("/a/b/y"))
+	assert.Equal(t, []string{"root", "id", "x"}, run("/a/c/x"))
 }
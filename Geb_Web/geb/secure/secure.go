@@ -0,0 +1,86 @@
+// Package secure 安全相关响应头中间件
+package secure
+
+import (
+	"fmt"
+	"geb"
+	"strings"
+	"time"
+)
+
+// Config 安全响应头配置，零值表示不设置对应的响应头
+type Config struct {
+	// HSTSMaxAge Strict-Transport-Security的max-age，只对HTTPS请求
+	// （包括X-Forwarded-Proto为https的请求）生效
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// ContentSecurityPolicy Content-Security-Policy的值
+	ContentSecurityPolicy string
+	// FrameOptions X-Frame-Options的值，例如DENY或SAMEORIGIN
+	FrameOptions string
+	// ContentTypeNosniff 设置X-Content-Type-Options: nosniff
+	ContentTypeNosniff bool
+	// ReferrerPolicy Referrer-Policy的值
+	ReferrerPolicy string
+	// PermissionsPolicy Permissions-Policy的值
+	PermissionsPolicy string
+	// CrossOriginOpenerPolicy Cross-Origin-Opener-Policy的值
+	CrossOriginOpenerPolicy string
+}
+
+// DefaultConfig 一组适合大多数服务的默认值
+func DefaultConfig() Config {
+	return Config{
+		HSTSMaxAge:              365 * 24 * time.Hour,
+		HSTSIncludeSubdomains:   true,
+		ContentSecurityPolicy:   "default-src 'self'",
+		FrameOptions:            "DENY",
+		ContentTypeNosniff:      true,
+		ReferrerPolicy:          "strict-origin-when-cross-origin",
+		CrossOriginOpenerPolicy: "same-origin",
+	}
+}
+
+func Default() geb.HandlerFunc {
+	return New(DefaultConfig())
+}
+
+// New 在执行后续处理函数之前写入配置的响应头
+func New(conf Config) geb.HandlerFunc {
+	hsts := ""
+	if conf.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", int64(conf.HSTSMaxAge/time.Second))
+		if conf.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if conf.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	headers := map[string]string{
+		"Content-Security-Policy":    conf.ContentSecurityPolicy,
+		"X-Frame-Options":            conf.FrameOptions,
+		"Referrer-Policy":            conf.ReferrerPolicy,
+		"Permissions-Policy":         conf.PermissionsPolicy,
+		"Cross-Origin-Opener-Policy": conf.CrossOriginOpenerPolicy,
+	}
+	if conf.ContentTypeNosniff {
+		headers["X-Content-Type-Options"] = "nosniff"
+	}
+	return func(c *geb.Context) {
+		h := c.Writer.Header()
+		for k, v := range headers {
+			if v != "" {
+				h.Set(k, v)
+			}
+		}
+		if hsts != "" && isHTTPS(c) {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+	}
+}
+
+func isHTTPS(c *geb.Context) bool {
+	return c.Req.TLS != nil || strings.EqualFold(c.Req.Header.Get("X-Forwarded-Proto"), "https")
+}
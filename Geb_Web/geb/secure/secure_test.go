@@ -0,0 +1,46 @@
+package secure
+
+import (
+	"geb"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDefault(t *testing.T) {
+	e := geb.New()
+	e.Use(Default())
+	e.GET("/a", func(c *geb.Context) {
+		c.Text(http.StatusOK, "a")
+	})
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/a", nil))
+	h := w.Header()
+	assert.Equal(t, "nosniff", h.Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", h.Get("X-Frame-Options"))
+	assert.Equal(t, "default-src 'self'", h.Get("Content-Security-Policy"))
+	assert.Equal(t, "strict-origin-when-cross-origin", h.Get("Referrer-Policy"))
+	assert.Empty(t, h.Get("Strict-Transport-Security"))
+
+	req := httptest.NewRequest("GET", "/a", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, "max-age=31536000; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+}
+
+func TestConfig(t *testing.T) {
+	e := geb.New()
+	e.Use(New(Config{HSTSMaxAge: time.Minute, HSTSPreload: true, FrameOptions: "SAMEORIGIN"}))
+	e.GET("/a", func(c *geb.Context) {})
+	req := httptest.NewRequest("GET", "https://x.com/a", nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	h := w.Header()
+	assert.Equal(t, "max-age=60; preload", h.Get("Strict-Transport-Security"))
+	assert.Equal(t, "SAMEORIGIN", h.Get("X-Frame-Options"))
+	assert.Empty(t, h.Get("X-Content-Type-Options"))
+	assert.Empty(t, h.Get("Content-Security-Policy"))
+}
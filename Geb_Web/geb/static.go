@@ -0,0 +1,159 @@
+package geb
+
+import (
+	"fmt"
+	"hash/crc32"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StaticConfig 静态文件服务配置
+type StaticConfig struct {
+	// Index 目录的默认文件，默认为index.html
+	Index string
+	// Browse 目录中没有Index文件时是否列出目录内容
+	Browse bool
+	// SPA 文件不存在时返回根目录下的Index文件，用于单页应用的前端路由
+	SPA bool
+}
+
+// Static 将prefix下的请求映射到本地目录dir
+func (g *RouterGroup) Static(prefix, dir string) error {
+	return g.StaticFS(prefix, os.DirFS(dir))
+}
+
+// StaticFS 将prefix下的请求映射到fsys，fsys可以是embed.FS（需要时用fs.Sub去掉目录前缀）
+func (g *RouterGroup) StaticFS(prefix string, fsys fs.FS) error {
+	return g.StaticWithConfig(prefix, fsys, StaticConfig{})
+}
+
+// StaticWithConfig 与StaticFS相同，可以配置目录列表与单页应用回退，
+// 支持Range请求，以及基于Last-Modified和ETag的条件请求
+func (g *RouterGroup) StaticWithConfig(prefix string, fsys fs.FS, conf StaticConfig) error {
+	if conf.Index == "" {
+		conf.Index = "index.html"
+	}
+	s := &staticServer{fsys: fsys, conf: conf, prefix: joinPaths(g.prefix, prefix)}
+	for _, p := range []string{prefix, strings.TrimSuffix(prefix, "/") + "/*filepath"} {
+		if err := g.GET(p, s.serve); err != nil {
+			return err
+		}
+		if err := g.HEAD(p, s.serve); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type staticServer struct {
+	fsys   fs.FS
+	conf   StaticConfig
+	prefix string
+	// 没有修改时间的文件（例如embed.FS中的文件）按内容计算ETag，结果缓存在这里
+	etags sync.Map
+}
+
+func (s *staticServer) serve(c *Context) {
+	name := strings.TrimPrefix(path.Clean("/"+c.Param("filepath")), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		c.Fail(http.StatusBadRequest)
+		return
+	}
+	if s.serveFile(c, name) {
+		return
+	}
+	if s.conf.SPA && s.serveFile(c, s.conf.Index) {
+		return
+	}
+	c.Fail(http.StatusNotFound)
+}
+
+// 文件不存在时返回false
+func (s *staticServer) serveFile(c *Context, name string) bool {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		index := path.Join(name, s.conf.Index)
+		if fi, err := fs.Stat(s.fsys, index); err == nil && !fi.IsDir() {
+			return s.serveFile(c, index)
+		}
+		if !s.conf.Browse {
+			return false
+		}
+		s.listDir(c, name)
+		return true
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		c.Fail(http.StatusInternalServerError)
+		return true
+	}
+	if etag := s.etag(name, info, rs); etag != "" {
+		c.SetHeader("ETag", etag)
+	}
+	http.ServeContent(c.Writer, c.Req, info.Name(), info.ModTime(), rs)
+	return true
+}
+
+func (s *staticServer) etag(name string, info fs.FileInfo, rs io.ReadSeeker) string {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+	}
+	if v, ok := s.etags.Load(name); ok {
+		return v.(string)
+	}
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, rs); err != nil {
+		return ""
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	etag := fmt.Sprintf(`"%x-%x"`, info.Size(), h.Sum32())
+	s.etags.Store(name, etag)
+	return etag
+}
+
+func (s *staticServer) listDir(c *Context, name string) {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		c.Fail(http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	base := strings.TrimSuffix(s.prefix, "/")
+	if name != "." {
+		base += "/" + name
+	}
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<pre>\n")
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() {
+			n += "/"
+		}
+		u := url.URL{Path: base + "/" + n}
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(u.String()), html.EscapeString(n))
+	}
+	b.WriteString("</pre>\n")
+	c.SetContentType("text/html; charset=utf-8")
+	c.Data(http.StatusOK, []byte(b.String()))
+}
@@ -0,0 +1,83 @@
+package geb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func serve(e *Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestStatic(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "css", "main.css"), "body{}")
+	writeFile(t, filepath.Join(dir, "index.html"), "home")
+	writeFile(t, filepath.Join(dir, "docs", "a.txt"), "0123456789")
+	e := New()
+	assert.Nil(t, e.Static("/static", dir))
+
+	w := serve(e, httptest.NewRequest("GET", "/static/css/main.css", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "body{}", w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/css")
+	etag := w.Header().Get("ETag")
+	lastModified := w.Header().Get("Last-Modified")
+	assert.NotEmpty(t, etag)
+	assert.NotEmpty(t, lastModified)
+
+	req := httptest.NewRequest("GET", "/static/css/main.css", nil)
+	req.Header.Set("If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, serve(e, req).Code)
+	req = httptest.NewRequest("GET", "/static/css/main.css", nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	assert.Equal(t, http.StatusNotModified, serve(e, req).Code)
+
+	req = httptest.NewRequest("GET", "/static/docs/a.txt", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	w = serve(e, req)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "234", w.Body.String())
+
+	assert.Equal(t, "home", serve(e, httptest.NewRequest("GET", "/static", nil)).Body.String())
+	assert.Equal(t, http.StatusNotFound, serve(e, httptest.NewRequest("GET", "/static/docs", nil)).Code)
+	assert.Equal(t, http.StatusNotFound, serve(e, httptest.NewRequest("GET", "/static/none.js", nil)).Code)
+	assert.Equal(t, http.StatusNotFound, serve(e, httptest.NewRequest("GET", "/static/../static_test.go", nil)).Code)
+}
+
+func TestStaticFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":   {Data: []byte("app")},
+		"js/app.js":    {Data: []byte("run()")},
+		"img/logo.png": {Data: []byte("png")},
+	}
+	e := New()
+	api := e.Group("/ui")
+	assert.Nil(t, api.StaticWithConfig("/", fsys, StaticConfig{Browse: true, SPA: true}))
+
+	w := serve(e, httptest.NewRequest("GET", "/ui/js/app.js", nil))
+	assert.Equal(t, "run()", w.Body.String())
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	req := httptest.NewRequest("GET", "/ui/js/app.js", nil)
+	req.Header.Set("If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, serve(e, req).Code)
+
+	w = serve(e, httptest.NewRequest("GET", "/ui/img", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<a href="/ui/img/logo.png">logo.png</a>`)
+
+	// 单页应用回退
+	w = serve(e, httptest.NewRequest("GET", "/ui/users/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "app", w.Body.String())
+
+	w = serve(e, httptest.NewRequest("HEAD", "/ui/js/app.js", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,197 @@
+package geb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// UploadConfig 文件上传配置
+type UploadConfig struct {
+	// MaxBodySize 请求体的最大字节数，超过时返回413，默认32MB
+	MaxBodySize int64
+	// MaxMemory 解析时保存在内存中的最大字节数，超过的文件写入临时文件，默认8MB
+	MaxMemory int64
+	// TempDir SaveUploadedFile写入时使用的临时目录，默认与目标文件相同，保证可以原子地重命名
+	TempDir string
+	// FileMode SaveUploadedFile保存的文件的权限，默认0644；
+	// 临时文件创建时为0600，重命名前改为该权限
+	FileMode os.FileMode
+	// Hash 计算校验和的算法，默认sha256
+	Hash func() hash.Hash
+	// Inspect SaveUploadedFile写完临时文件后、重命名为目标文件前调用，
+	// 返回错误时删除临时文件并放弃保存，可用于检查文件类型与校验和
+	Inspect func(fh *multipart.FileHeader, info UploadInfo) error
+}
+
+// UploadInfo 保存上传文件时计算出的信息
+type UploadInfo struct {
+	// ContentType 按文件的前512字节嗅探出的类型，与客户端声明的类型无关
+	ContentType string
+	Size        int64
+	// Checksum 十六进制的校验和
+	Checksum string
+}
+
+const (
+	defaultMaxBodySize = 32 << 20
+	defaultMaxMemory   = 8 << 20
+	defaultFileMode    = 0644
+)
+
+// SetUploadConfig 设置文件上传配置，必须在启动前调用
+func (e *Engine) SetUploadConfig(conf UploadConfig) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.state != StateIdle {
+		return fmt.Errorf("engine has started")
+	}
+	e.uploads = conf
+	return nil
+}
+
+func (c *Context) uploadConfig() UploadConfig {
+	var conf UploadConfig
+	if c.engine != nil {
+		conf = c.engine.uploads
+	}
+	if conf.MaxBodySize <= 0 {
+		conf.MaxBodySize = defaultMaxBodySize
+	}
+	if conf.MaxMemory <= 0 {
+		conf.MaxMemory = defaultMaxMemory
+	}
+	if conf.FileMode == 0 {
+		conf.FileMode = defaultFileMode
+	}
+	if conf.Hash == nil {
+		conf.Hash = sha256.New
+	}
+	return conf
+}
+
+// 读取超过n字节时返回错误并记录exceeded
+type limitedBody struct {
+	io.ReadCloser
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		l.exceeded = true
+		return 0, fmt.Errorf("请求体超过上限")
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// MultipartForm 解析multipart/form-data请求体，结果会被缓存；
+// 请求体超过MaxBodySize时返回413错误，格式错误时返回400错误，
+// 写入磁盘的临时文件在请求结束后由http.Server删除
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if c.Req.MultipartForm != nil {
+		return c.Req.MultipartForm, nil
+	}
+	conf := c.uploadConfig()
+	tooLarge := NewHTTPError(http.StatusRequestEntityTooLarge, "")
+	if c.Req.ContentLength > conf.MaxBodySize {
+		return nil, tooLarge
+	}
+	body := &limitedBody{ReadCloser: c.Req.Body, n: conf.MaxBodySize + 1}
+	c.Req.Body = body
+	err := c.Req.ParseMultipartForm(conf.MaxMemory)
+	c.Req.Body = body.ReadCloser
+	if body.exceeded || (err == nil && body.n <= 0) {
+		if c.Req.MultipartForm != nil {
+			c.Req.MultipartForm.RemoveAll()
+			c.Req.MultipartForm = nil
+		}
+		return nil, tooLarge
+	}
+	if err != nil {
+		return nil, &HTTPError{Code: http.StatusBadRequest, Message: "无法解析multipart请求", Err: err}
+	}
+	return c.Req.MultipartForm, nil
+}
+
+// FormFile 返回名为name的第一个上传文件，不存在时返回http.ErrMissingFile
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if fhs := form.File[name]; len(fhs) > 0 {
+		return fhs[0], nil
+	}
+	return nil, http.ErrMissingFile
+}
+
+// SaveUploadedFile 将上传文件保存到dst，目录不存在时自动创建；
+// 写入时同时嗅探类型并计算校验和，先写入临时文件，通过Inspect检查后再重命名为dst
+func (c *Context) SaveUploadedFile(fh *multipart.FileHeader, dst string) (UploadInfo, error) {
+	conf := c.uploadConfig()
+	var info UploadInfo
+	src, err := fh.Open()
+	if err != nil {
+		return info, err
+	}
+	defer src.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return info, err
+	}
+	dir := conf.TempDir
+	if dir == "" {
+		dir = filepath.Dir(dst)
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return info, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := conf.Hash()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		tmp.Close()
+		return info, err
+	}
+	head = head[:n]
+	info.ContentType = http.DetectContentType(head)
+	w := io.MultiWriter(tmp, h)
+	if _, err := w.Write(head); err != nil {
+		tmp.Close()
+		return info, err
+	}
+	size, err := io.Copy(w, src)
+	if err != nil {
+		tmp.Close()
+		return info, err
+	}
+	if err := tmp.Chmod(conf.FileMode); err != nil {
+		tmp.Close()
+		return info, err
+	}
+	if err := tmp.Close(); err != nil {
+		return info, err
+	}
+	info.Size = int64(n) + size
+	info.Checksum = hex.EncodeToString(h.Sum(nil))
+	if conf.Inspect != nil {
+		if err := conf.Inspect(fh, info); err != nil {
+			return info, err
+		}
+	}
+	return info, os.Rename(tmp.Name(), dst)
+}
@@ -0,0 +1,140 @@
+package geb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func uploadReq(t *testing.T, files map[string]string) *http.Request {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	assert.Nil(t, mw.WriteField("kind", "csv"))
+	for name, content := range files {
+		fw, err := mw.CreateFormFile(name, name+".csv")
+		assert.Nil(t, err)
+		fw.Write([]byte(content))
+	}
+	assert.Nil(t, mw.Close())
+	req := httptest.NewRequest("POST", "/upload", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestFormFile(t *testing.T) {
+	dir := t.TempDir()
+	content := "id,name\n1,a\n2,b\n"
+	e := New()
+	assert.Nil(t, e.POST("/upload", func(c *Context) {
+		form, err := c.MultipartForm()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"csv"}, form.Value["kind"])
+		_, err = c.FormFile("missing")
+		assert.Equal(t, http.ErrMissingFile, err)
+
+		fh, err := c.FormFile("data")
+		if c.Error(err) != nil {
+			return
+		}
+		info, err := c.SaveUploadedFile(fh, filepath.Join(dir, "sub", "data.csv"))
+		if c.Error(err) != nil {
+			return
+		}
+		c.JSON(http.StatusOK, info)
+	}))
+	w := serve(e, uploadReq(t, map[string]string{"data": content}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	sum := sha256.Sum256([]byte(content))
+	assert.JSONEq(t, `{"ContentType":"text/plain; charset=utf-8","Size":16,"Checksum":"`+hex.EncodeToString(sum[:])+`"}`, w.Body.String())
+	b, err := os.ReadFile(filepath.Join(dir, "sub", "data.csv"))
+	assert.Nil(t, err)
+	assert.Equal(t, content, string(b))
+	// 与os.Create创建的文件一样可被其他用户读取，而不是临时文件的0600
+	fi, err := os.Stat(filepath.Join(dir, "sub", "data.csv"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0644), fi.Mode().Perm())
+	// 没有残留临时文件
+	entries, _ := os.ReadDir(filepath.Join(dir, "sub"))
+	assert.Len(t, entries, 1)
+}
+
+func TestUploadLimits(t *testing.T) {
+	e := New()
+	assert.Nil(t, e.SetUploadConfig(UploadConfig{MaxBodySize: 1024, MaxMemory: 64}))
+	assert.Nil(t, e.POST("/upload", func(c *Context) {
+		fh, err := c.FormFile("data")
+		if c.Error(err) != nil {
+			return
+		}
+		f, err := fh.Open()
+		assert.Nil(t, err)
+		defer f.Close()
+		// 超过MaxMemory的文件写入临时文件
+		_, onDisk := f.(*os.File)
+		c.Text(http.StatusOK, fh.Filename+" "+map[bool]string{true: "disk", false: "memory"}[onDisk])
+	}))
+
+	w := serve(e, uploadReq(t, map[string]string{"data": strings.Repeat("x", 512)}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "data.csv disk", w.Body.String())
+
+	w = serve(e, uploadReq(t, map[string]string{"data": "small"}))
+	assert.Equal(t, "data.csv memory", w.Body.String())
+
+	w = serve(e, uploadReq(t, map[string]string{"data": strings.Repeat("x", 2048)}))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// 未声明长度的请求体在读取时截断
+	req := uploadReq(t, map[string]string{"data": strings.Repeat("x", 2048)})
+	req.ContentLength = -1
+	w = serve(e, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	req = httptest.NewRequest("POST", "/upload", strings.NewReader("a=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = serve(e, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUploadInspect(t *testing.T) {
+	dir := t.TempDir()
+	e := New()
+	var seen UploadInfo
+	assert.Nil(t, e.SetUploadConfig(UploadConfig{FileMode: 0600, Inspect: func(fh *multipart.FileHeader, info UploadInfo) error {
+		seen = info
+		if !strings.HasPrefix(info.ContentType, "text/plain") {
+			return errors.New("只接受文本文件")
+		}
+		return nil
+	}}))
+	assert.Nil(t, e.POST("/upload", func(c *Context) {
+		fh, err := c.FormFile("data")
+		if c.Error(err) != nil {
+			return
+		}
+		_, err = c.SaveUploadedFile(fh, filepath.Join(dir, "data"))
+		c.Error(err)
+	}))
+
+	w := serve(e, uploadReq(t, map[string]string{"data": "\x89PNG\r\n\x1a\n0000"}))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "image/png", seen.ContentType)
+	assert.Equal(t, int64(12), seen.Size)
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+
+	w = serve(e, uploadReq(t, map[string]string{"data": "a,b\n"}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	fi, err := os.Stat(filepath.Join(dir, "data"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+}
@@ -0,0 +1,227 @@
+package geb
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 校验规则写在binding标签中，多个规则用","分隔，例如：
+//
+//	Name string `json:"name" binding:"required,min=2,max=16"`
+//	Role string `form:"role" binding:"oneof=admin user"`
+//	Code string `form:"code" binding:"regexp=^[a-z]+$"`
+//
+// required：不能为零值
+//
+// min/max：字符串、切片、map比较长度，数字比较数值
+//
+// oneof：值必须是空格分隔的候选之一
+//
+// regexp：字符串必须匹配正则表达式，正则表达式中不能包含","
+//
+// 嵌套的结构体字段会被递归校验
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors 全部校验失败的字段，可直接作为400响应的JSON输出
+type ValidationErrors []FieldError
+
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, e := range ve {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Fields 返回字段名到错误信息的映射，同一字段只保留第一个错误
+func (ve ValidationErrors) Fields() map[string]string {
+	m := make(map[string]string, len(ve))
+	for _, e := range ve {
+		if _, ok := m[e.Field]; !ok {
+			m[e.Field] = e.Message
+		}
+	}
+	return m
+}
+
+var (
+	regexpMu    sync.RWMutex
+	regexpCache = make(map[string]*regexp.Regexp)
+)
+
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	regexpMu.RLock()
+	re, ok := regexpCache[expr]
+	regexpMu.RUnlock()
+	if ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexpMu.Lock()
+	regexpCache[expr] = re
+	regexpMu.Unlock()
+	return re, nil
+}
+
+// validate 按binding标签校验obj，校验失败时返回ValidationErrors
+func validate(obj interface{}) error {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := validateStruct(v, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		name := prefix + fieldName(sf)
+		if sf.Anonymous {
+			name = prefix
+		}
+		if tag := sf.Tag.Get("binding"); tag != "" && tag != "-" {
+			for _, rule := range strings.Split(tag, ",") {
+				fe, err := checkRule(fv, name, rule)
+				if err != nil {
+					return err
+				}
+				if fe != nil {
+					*errs = append(*errs, *fe)
+					break
+				}
+			}
+		}
+		inner := fv
+		if inner.Kind() == reflect.Ptr && !inner.IsNil() {
+			inner = inner.Elem()
+		}
+		if inner.Kind() == reflect.Struct {
+			p := name + "."
+			if sf.Anonymous {
+				p = prefix
+			}
+			if err := validateStruct(inner, p, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 返回的error表示规则本身有误，属于编程错误
+func checkRule(fv reflect.Value, name, rule string) (*FieldError, error) {
+	key, arg := rule, ""
+	if i := strings.Index(rule, "="); i >= 0 {
+		key, arg = rule[:i], rule[i+1:]
+	}
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			if key == "required" {
+				return &FieldError{name, key, fmt.Sprintf("%s不能为空", name)}, nil
+			}
+			return nil, nil
+		}
+		fv = fv.Elem()
+	}
+	switch key {
+	case "required":
+		if fv.IsZero() {
+			return &FieldError{name, key, fmt.Sprintf("%s不能为空", name)}, nil
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("字段%s的规则%s有误", name, rule)
+		}
+		n, isLen, ok := measure(fv)
+		if !ok {
+			return nil, fmt.Errorf("字段%s的类型不支持规则%s", name, rule)
+		}
+		if (key == "min" && n >= limit) || (key == "max" && n <= limit) {
+			return nil, nil
+		}
+		what := "的值"
+		if isLen {
+			what = "的长度"
+		}
+		cmp := "不能小于"
+		if key == "max" {
+			cmp = "不能大于"
+		}
+		return &FieldError{name, key, fmt.Sprintf("%s%s%s%s", name, what, cmp, arg)}, nil
+	case "oneof":
+		s := fmt.Sprint(fv.Interface())
+		for _, o := range strings.Fields(arg) {
+			if o == s {
+				return nil, nil
+			}
+		}
+		return &FieldError{name, key, fmt.Sprintf("%s必须是[%s]之一", name, arg)}, nil
+	case "regexp":
+		if fv.Kind() != reflect.String {
+			return nil, fmt.Errorf("字段%s的类型不支持规则%s", name, rule)
+		}
+		re, err := compileRegexp(arg)
+		if err != nil {
+			return nil, fmt.Errorf("字段%s的规则%s有误：%v", name, rule, err)
+		}
+		if !re.MatchString(fv.String()) {
+			return &FieldError{name, key, fmt.Sprintf("%s的格式不正确", name)}, nil
+		}
+	default:
+		return nil, fmt.Errorf("无法识别的校验规则%s", rule)
+	}
+	return nil, nil
+}
+
+// 返回用于min/max比较的数值，isLen表示比较的是长度
+func measure(fv reflect.Value) (n float64, isLen bool, ok bool) {
+	switch fv.Kind() {
+	case reflect.String:
+		return float64(len([]rune(fv.String()))), true, true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fv.Len()), true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), false, true
+	}
+	return 0, false, false
+}
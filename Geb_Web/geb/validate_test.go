@@ -0,0 +1,38 @@
+package geb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	type inner struct {
+		Age int `json:"age" binding:"min=0,max=150"`
+	}
+	type outer struct {
+		ID    *int   `json:"id" binding:"required"`
+		Email string `json:"email" binding:"required,regexp=^[^@]+@[^@]+$"`
+		Inner inner  `json:"inner"`
+		Items []int  `json:"items" binding:"min=1"`
+	}
+	id := 1
+	assert.Nil(t, validate(&outer{ID: &id, Email: "a@b", Items: []int{1}}))
+
+	err := validate(&outer{Email: "ab", Inner: inner{Age: 200}})
+	ve, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, ValidationErrors{
+		{Field: "id", Rule: "required", Message: "id不能为空"},
+		{Field: "email", Rule: "regexp", Message: "email的格式不正确"},
+		{Field: "inner.age", Rule: "max", Message: "inner.age的值不能大于150"},
+		{Field: "items", Rule: "min", Message: "items的长度不能小于1"},
+	}, ve)
+
+	type bad struct {
+		A string `binding:"unknown"`
+	}
+	err = validate(&bad{})
+	_, ok = err.(ValidationErrors)
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,562 @@
+package geb
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket实现，遵循RFC 6455，不支持扩展（例如permessage-deflate）
+//
+// 在路由中使用：
+//
+//	e.GET("/ws", func(c *geb.Context) {
+//		conn, err := c.Upgrade()
+//		if err != nil {
+//			return
+//		}
+//		defer conn.Close()
+//		for {
+//			typ, data, err := conn.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			conn.WriteMessage(typ, data)
+//		}
+//	})
+//
+// 升级发生在处理函数中，因此之前的中间件（例如鉴权）会先执行
+
+// 消息类型，与帧的opcode相同
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// 关闭码
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	wsGUID             = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	maxControlPayload  = 125
+	defaultReadLimit   = 32 << 20
+	continuationFrame  = 0
+	defaultCloseWait   = time.Second
+	finalBit           = 0x80
+	maskBit            = 0x80
+	reservedBits       = 0x70
+	opcodeMask         = 0x0f
+	payloadLen16       = 126
+	payloadLen64       = 127
+	defaultHandshakeTO = 10 * time.Second
+)
+
+// CloseError 对端发送了关闭帧，或因协议错误关闭连接
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// ErrWebSocketClosed 连接已经关闭后继续读写
+var ErrWebSocketClosed = errors.New("websocket: connection closed")
+
+// WebSocketConfig 升级配置
+type WebSocketConfig struct {
+	// Subprotocols 服务端支持的子协议，按优先级排列
+	Subprotocols []string
+	// CheckOrigin 返回false时拒绝升级，默认只允许没有Origin或Origin与Host相同的请求
+	CheckOrigin func(req *http.Request) bool
+	// ReadLimit 单条消息的最大字节数，默认32MB
+	ReadLimit int64
+}
+
+// WebSocketConn 一个WebSocket连接，同一时间只允许一个goroutine读、一个goroutine写，
+// WriteControl可以与WriteMessage并发调用
+type WebSocketConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	isServer    bool
+	subprotocol string
+
+	writeMu sync.Mutex
+	closed  bool
+
+	readLimit   int64
+	pingHandler func(data []byte) error
+	pongHandler func(data []byte) error
+}
+
+func newWebSocketConn(conn net.Conn, br *bufio.Reader, isServer bool, readLimit int64) *WebSocketConn {
+	if readLimit <= 0 {
+		readLimit = defaultReadLimit
+	}
+	ws := &WebSocketConn{conn: conn, br: br, isServer: isServer, readLimit: readLimit}
+	ws.pingHandler = func(data []byte) error {
+		err := ws.WriteControl(PongMessage, data, time.Now().Add(defaultCloseWait))
+		if errors.Is(err, ErrWebSocketClosed) {
+			return nil
+		}
+		return err
+	}
+	ws.pongHandler = func([]byte) error { return nil }
+	return ws
+}
+
+// Upgrade 使用默认配置将请求升级为WebSocket连接
+func (c *Context) Upgrade() (*WebSocketConn, error) {
+	return c.UpgradeWithConfig(WebSocketConfig{})
+}
+
+// UpgradeWithConfig 完成WebSocket握手，失败时已向客户端写出错误响应，
+// 成功后处理链被终止，之后的处理函数不会执行
+func (c *Context) UpgradeWithConfig(conf WebSocketConfig) (*WebSocketConn, error) {
+	req := c.Req
+	fail := func(code int, msg string) (*WebSocketConn, error) {
+		c.AbortWithStatus(code)
+		return nil, NewHTTPError(code, "websocket: "+msg)
+	}
+	if req.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "请求方法必须是GET")
+	}
+	if !headerContains(req.Header, "Connection", "upgrade") || !headerContains(req.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "缺少Upgrade请求头")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.SetHeader("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "不支持的协议版本")
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if k, err := base64.StdEncoding.DecodeString(key); err != nil || len(k) != 16 {
+		return fail(http.StatusBadRequest, "Sec-WebSocket-Key错误")
+	}
+	checkOrigin := conf.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(req) {
+		return fail(http.StatusForbidden, "Origin不被允许")
+	}
+	subprotocol := ""
+	for _, p := range conf.Subprotocols {
+		if headerContains(req.Header, "Sec-WebSocket-Protocol", p) {
+			subprotocol = p
+			break
+		}
+	}
+
+	conn, rw, err := c.Writer.Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, err.Error())
+	}
+	c.Abort()
+	if rw.Reader.Buffered() > 0 {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: 握手完成前客户端发送了数据")
+	}
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	b.WriteString("\r\n")
+	conn.SetWriteDeadline(time.Now().Add(defaultHandshakeTO))
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetWriteDeadline(time.Time{})
+	// 握手时设置的超时不再生效
+	conn.SetReadDeadline(time.Time{})
+	ws := newWebSocketConn(conn, rw.Reader, true, conf.ReadLimit)
+	ws.subprotocol = subprotocol
+	return ws, nil
+}
+
+// DialWebSocket 作为客户端连接rawurl，支持ws与http协议头（不支持wss），主要用于测试
+func DialWebSocket(rawurl string, header http.Header) (*WebSocketConn, *http.Response, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "http"
+	default:
+		return nil, nil, fmt.Errorf("websocket: 不支持的协议%s", u.Scheme)
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, defaultHandshakeTO)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyBytes := make([]byte, 16)
+	rand.Read(keyBytes)
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+	req := &http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}, Host: u.Host}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	conn.SetDeadline(time.Now().Add(defaultHandshakeTO))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, res, fmt.Errorf("websocket: 握手失败，%s", res.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	ws := newWebSocketConn(conn, br, false, 0)
+	ws.subprotocol = res.Header.Get("Sec-WebSocket-Protocol")
+	return ws, res, nil
+}
+
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, v := range header.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, req.Host)
+}
+
+// Subprotocol 返回协商得到的子协议
+func (ws *WebSocketConn) Subprotocol() string {
+	return ws.subprotocol
+}
+
+func (ws *WebSocketConn) LocalAddr() net.Addr {
+	return ws.conn.LocalAddr()
+}
+
+func (ws *WebSocketConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+func (ws *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+func (ws *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// SetReadLimit 设置单条消息的最大字节数，超过时以1009关闭连接
+func (ws *WebSocketConn) SetReadLimit(limit int64) {
+	ws.readLimit = limit
+}
+
+// SetPingHandler 设置收到ping时的处理函数，默认回复相同内容的pong
+func (ws *WebSocketConn) SetPingHandler(h func(data []byte) error) {
+	ws.pingHandler = h
+}
+
+// SetPongHandler 设置收到pong时的处理函数，常用于延长读超时
+func (ws *WebSocketConn) SetPongHandler(h func(data []byte) error) {
+	ws.pongHandler = h
+}
+
+// WriteMessage 以单个帧发送一条文本或二进制消息
+func (ws *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: 无效的消息类型%d", messageType)
+	}
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	return ws.writeFrame(messageType, data)
+}
+
+// WriteControl 发送ping、pong或close控制帧，deadline为本次写入的超时时间
+func (ws *WebSocketConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType != PingMessage && messageType != PongMessage && messageType != CloseMessage {
+		return fmt.Errorf("websocket: 无效的控制帧类型%d", messageType)
+	}
+	if len(data) > maxControlPayload {
+		return fmt.Errorf("websocket: 控制帧长度不能超过%d", maxControlPayload)
+	}
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	ws.conn.SetWriteDeadline(deadline)
+	defer ws.conn.SetWriteDeadline(time.Time{})
+	return ws.writeFrame(messageType, data)
+}
+
+// Ping 发送一个ping帧
+func (ws *WebSocketConn) Ping(data []byte) error {
+	return ws.WriteControl(PingMessage, data, time.Now().Add(defaultCloseWait))
+}
+
+// 调用方需持有writeMu
+func (ws *WebSocketConn) writeFrame(opcode int, data []byte) error {
+	if ws.closed {
+		return ErrWebSocketClosed
+	}
+	header := make([]byte, 2, 14)
+	header[0] = finalBit | byte(opcode)
+	n := len(data)
+	switch {
+	case n < payloadLen16:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = payloadLen16
+		header = header[:4]
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header[1] = payloadLen64
+		header = header[:10]
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	payload := data
+	// 客户端发送的帧必须掩码
+	if !ws.isServer {
+		header[1] |= maskBit
+		var key [4]byte
+		rand.Read(key[:])
+		header = append(header, key[:]...)
+		payload = make([]byte, n)
+		copy(payload, data)
+		maskBytes(key, payload)
+	}
+	if opcode == CloseMessage {
+		ws.closed = true
+	}
+	if _, err := ws.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i%4]
+	}
+}
+
+type frame struct {
+	fin     bool
+	opcode  int
+	payload []byte
+}
+
+func (ws *WebSocketConn) readFrame(remain int64) (frame, error) {
+	var f frame
+	var h [2]byte
+	if _, err := io.ReadFull(ws.br, h[:]); err != nil {
+		return f, err
+	}
+	f.fin = h[0]&finalBit != 0
+	f.opcode = int(h[0] & opcodeMask)
+	if h[0]&reservedBits != 0 {
+		return f, ws.fail(CloseProtocolError, "不支持扩展位")
+	}
+	masked := h[1]&maskBit != 0
+	if masked != ws.isServer {
+		return f, ws.fail(CloseProtocolError, "掩码位错误")
+	}
+	n := int64(h[1] &^ maskBit)
+	switch n {
+	case payloadLen16:
+		var b [2]byte
+		if _, err := io.ReadFull(ws.br, b[:]); err != nil {
+			return f, err
+		}
+		n = int64(binary.BigEndian.Uint16(b[:]))
+	case payloadLen64:
+		var b [8]byte
+		if _, err := io.ReadFull(ws.br, b[:]); err != nil {
+			return f, err
+		}
+		n = int64(binary.BigEndian.Uint64(b[:]))
+		if n < 0 {
+			return f, ws.fail(CloseProtocolError, "帧长度错误")
+		}
+	}
+	isControl := f.opcode >= CloseMessage
+	if isControl && (!f.fin || n > maxControlPayload) {
+		return f, ws.fail(CloseProtocolError, "控制帧错误")
+	}
+	if !isControl && n > remain {
+		return f, ws.fail(CloseMessageTooBig, "消息过大")
+	}
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(ws.br, key[:]); err != nil {
+			return f, err
+		}
+	}
+	f.payload = make([]byte, n)
+	if _, err := io.ReadFull(ws.br, f.payload); err != nil {
+		return f, err
+	}
+	if masked {
+		maskBytes(key, f.payload)
+	}
+	return f, nil
+}
+
+// ReadMessage 读取下一条文本或二进制消息，分片的消息会被合并；期间收到的控制帧
+// 交给对应的处理函数，收到关闭帧时回复关闭帧并返回*CloseError
+func (ws *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		f, err := ws.readFrame(ws.readLimit - int64(len(data)))
+		if err != nil {
+			return 0, nil, err
+		}
+		switch f.opcode {
+		case PingMessage:
+			if err := ws.pingHandler(f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if err := ws.pongHandler(f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, ws.handleClose(f.payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "上一条消息尚未结束")
+			}
+			messageType = f.opcode
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "没有需要继续的消息")
+			}
+		default:
+			return 0, nil, ws.fail(CloseProtocolError, fmt.Sprintf("未知的opcode %d", f.opcode))
+		}
+		data = append(data, f.payload...)
+		if f.fin {
+			if messageType == TextMessage && !utf8.Valid(data) {
+				return 0, nil, ws.fail(CloseInvalidFramePayloadData, "文本消息不是合法的UTF-8")
+			}
+			return messageType, data, nil
+		}
+	}
+}
+
+func (ws *WebSocketConn) handleClose(payload []byte) error {
+	ce := &CloseError{Code: CloseNoStatusReceived}
+	if len(payload) == 1 {
+		return ws.fail(CloseProtocolError, "关闭帧错误")
+	}
+	if len(payload) >= 2 {
+		ce.Code = int(binary.BigEndian.Uint16(payload))
+		if !validCloseCode(ce.Code) {
+			return ws.fail(CloseProtocolError, fmt.Sprintf("非法的关闭码%d", ce.Code))
+		}
+		ce.Text = string(payload[2:])
+		if !utf8.ValidString(ce.Text) {
+			return ws.fail(CloseProtocolError, "关闭原因不是合法的UTF-8")
+		}
+	}
+	reply := []byte{}
+	if ce.Code != CloseNoStatusReceived {
+		reply = FormatCloseMessage(ce.Code, "")
+	}
+	ws.WriteControl(CloseMessage, reply, time.Now().Add(defaultCloseWait))
+	return ce
+}
+
+// 关闭帧中可以出现的关闭码，RFC 6455 7.4：1004保留，1005、1006、1015只在本地使用，
+// 1016-2999未分配，3000-4999供库与应用使用
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1014:
+		return code != 1004 && code != CloseNoStatusReceived && code != CloseAbnormalClosure
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// 发送关闭帧并返回对应的错误
+func (ws *WebSocketConn) fail(code int, text string) error {
+	ws.WriteControl(CloseMessage, FormatCloseMessage(code, text), time.Now().Add(defaultCloseWait))
+	return &CloseError{Code: code, Text: text}
+}
+
+// FormatCloseMessage 生成关闭帧的内容
+func FormatCloseMessage(code int, text string) []byte {
+	b := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(b, uint16(code))
+	return append(b, text...)
+}
+
+// CloseWithCode 发送关闭帧后关闭底层连接
+func (ws *WebSocketConn) CloseWithCode(code int, text string) error {
+	err := ws.WriteControl(CloseMessage, FormatCloseMessage(code, text), time.Now().Add(defaultCloseWait))
+	if errors.Is(err, ErrWebSocketClosed) {
+		err = nil
+	}
+	if cerr := ws.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Close 直接关闭底层连接，不发送关闭帧
+func (ws *WebSocketConn) Close() error {
+	return ws.conn.Close()
+}
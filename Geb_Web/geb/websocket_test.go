@@ -0,0 +1,174 @@
+package geb
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newWSServer(t *testing.T) *httptest.Server {
+	e := New()
+	e.Use(func(c *Context) {
+		if c.Req.Header.Get("Token") != "ok" {
+			c.Fail(http.StatusUnauthorized)
+		}
+	})
+	e.GET("/echo", func(c *Context) {
+		conn, err := c.UpgradeWithConfig(WebSocketConfig{Subprotocols: []string{"chat"}, ReadLimit: 1024})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(data) == "close" {
+				conn.CloseWithCode(CloseGoingAway, "bye")
+				return
+			}
+			conn.WriteMessage(typ, data)
+		}
+	})
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	srv := newWSServer(t)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/echo"
+
+	_, res, err := DialWebSocket(url, nil)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	header := http.Header{"Token": {"ok"}, "Sec-WebSocket-Protocol": {"x, chat"}}
+	conn, _, err := DialWebSocket(url, header)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+	assert.Equal(t, "chat", conn.Subprotocol())
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	assert.Nil(t, conn.WriteMessage(TextMessage, []byte("hello")))
+	typ, data, err := conn.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, TextMessage, typ)
+	assert.Equal(t, "hello", string(data))
+
+	big := make([]byte, 1000)
+	assert.Nil(t, conn.WriteMessage(BinaryMessage, big))
+	typ, data, err = conn.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, BinaryMessage, typ)
+	assert.Equal(t, big, data)
+
+	// 服务端自动回复pong
+	pong := make(chan string, 1)
+	conn.SetPongHandler(func(data []byte) error {
+		pong <- string(data)
+		return nil
+	})
+	assert.Nil(t, conn.Ping([]byte("p")))
+	assert.Nil(t, conn.WriteMessage(TextMessage, []byte("close")))
+	_, _, err = conn.ReadMessage()
+	assert.Equal(t, "p", <-pong)
+	var ce *CloseError
+	assert.True(t, errors.As(err, &ce))
+	assert.Equal(t, CloseGoingAway, ce.Code)
+	assert.Equal(t, "bye", ce.Text)
+	assert.Equal(t, ErrWebSocketClosed, conn.WriteMessage(TextMessage, nil))
+}
+
+func TestWebSocketReadLimit(t *testing.T) {
+	srv := newWSServer(t)
+	conn, _, err := DialWebSocket(srv.URL+"/echo", http.Header{"Token": {"ok"}})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	assert.Nil(t, conn.WriteMessage(BinaryMessage, make([]byte, 2048)))
+	_, _, err = conn.ReadMessage()
+	var ce *CloseError
+	assert.True(t, errors.As(err, &ce))
+	assert.Equal(t, CloseMessageTooBig, ce.Code)
+}
+
+func TestWebSocketCloseCode(t *testing.T) {
+	srv := newWSServer(t)
+	tests := []struct {
+		code  int
+		reply int
+	}{
+		{CloseNormalClosure, CloseNormalClosure},
+		{CloseGoingAway, CloseGoingAway},
+		{CloseInvalidFramePayloadData, CloseInvalidFramePayloadData},
+		{1014, 1014},
+		{3000, 3000},
+		{4999, 4999},
+		{0, CloseProtocolError},
+		{999, CloseProtocolError},
+		{1004, CloseProtocolError},
+		{CloseNoStatusReceived, CloseProtocolError},
+		{CloseAbnormalClosure, CloseProtocolError},
+		{1015, CloseProtocolError},
+		{1016, CloseProtocolError},
+		{2999, CloseProtocolError},
+		{5000, CloseProtocolError},
+	}
+	for _, tt := range tests {
+		conn, _, err := DialWebSocket(srv.URL+"/echo", http.Header{"Token": {"ok"}})
+		if !assert.Nil(t, err) {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		assert.Nil(t, conn.WriteControl(CloseMessage, FormatCloseMessage(tt.code, ""), time.Now().Add(time.Second)))
+		_, _, err = conn.ReadMessage()
+		var ce *CloseError
+		if assert.True(t, errors.As(err, &ce), tt.code) {
+			assert.Equal(t, tt.reply, ce.Code, tt.code)
+		}
+		conn.Close()
+	}
+}
+
+func TestWebSocketBadHandshake(t *testing.T) {
+	e := New()
+	var upgradeErr error
+	e.GET("/ws", func(c *Context) {
+		_, upgradeErr = c.Upgrade()
+	})
+	req := httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set("Token", "ok")
+	w := serve(e, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Error(t, upgradeErr)
+
+	req = httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "8")
+	w = serve(e, req)
+	assert.Equal(t, http.StatusUpgradeRequired, w.Code)
+	assert.Equal(t, "13", w.Header().Get("Sec-WebSocket-Version"))
+
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "http://evil.com")
+	w = serve(e, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestAcceptKey(t *testing.T) {
+	// RFC 6455 1.3节中的例子
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
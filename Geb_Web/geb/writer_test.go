@@ -0,0 +1,26 @@
+package geb
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := newResponseWriter(rec)
+	assert.False(t, w.Written())
+	assert.Equal(t, http.StatusOK, w.Status())
+
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusInternalServerError)
+	n, err := w.Write([]byte("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, n)
+	assert.True(t, w.Written())
+	assert.Equal(t, http.StatusCreated, w.Status())
+	assert.Equal(t, 5, w.Size())
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, rec, w.Unwrap())
+}